@@ -3,71 +3,79 @@ package main
 import (
 	"flag"
 	"log"
-	"os"
 
-	"gopkg.in/yaml.v3"
+	"recommend_engine/internal/config"
 )
 
 // LLMGlobalConfig 对应 configs/llm.yaml
 type LLMGlobalConfig struct {
 	LLMs map[string]struct {
-		ChatEndpoint string `yaml:"chat_endpoint"` // 完整的 API 地址
-		APIKey       string `yaml:"api_key"`
-		Model        string `yaml:"model"`
-	} `yaml:"llms"`
+		ChatEndpoint string `yaml:"chat_endpoint" json:"chat_endpoint"` // 完整的 API 地址
+		APIKey       string `yaml:"api_key" json:"api_key"`             // 建议通过 ${ENV_VAR} 从环境变量读取
+		Model        string `yaml:"model" json:"model"`
+	} `yaml:"llms" json:"llms"`
 }
 
 // ServerConfig 对应 configs/server.yaml
 type ServerConfig struct {
 	Server struct {
-		Port  string `yaml:"port"`
-		Debug bool   `yaml:"debug"`
-	} `yaml:"server"`
+		Port  string `yaml:"port" json:"port"`
+		Debug bool   `yaml:"debug" json:"debug"`
+	} `yaml:"server" json:"server"`
 	Paths struct {
-		Users     string `yaml:"users"`
-		Pipelines string `yaml:"pipelines"`
-		LLM       string `yaml:"llm"`
-		History   string `yaml:"history"`
-	} `yaml:"paths"`
+		Users     string `yaml:"users" json:"users"`
+		Pipelines string `yaml:"pipelines" json:"pipelines"`
+		LLM       string `yaml:"llm" json:"llm"`
+		History   string `yaml:"history" json:"history"`
+	} `yaml:"paths" json:"paths"`
 }
 
+// loadLLMConfig 加载 LLM 配置，按扩展名支持 YAML / JSON，并展开环境变量引用
 func loadLLMConfig(path string) (*LLMGlobalConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
 	var cfg LLMGlobalConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := config.Load(path, &cfg); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
+// loadServerConfig 加载服务器配置，按扩展名支持 YAML / JSON，并展开环境变量引用
 func loadServerConfig(path string) (*ServerConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
 	var cfg ServerConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := config.Load(path, &cfg); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
-// InitServerConfig 初始化服务器配置，优先级：命令行参数 > 配置文件 > 默认值
-func InitServerConfig() *ServerConfig {
-	// 命令行参数
-	// 将默认值设置为空字符串，以便优先使用配置文件中的值
-	configPath := flag.String("config", "configs/server.yaml", "Path to server config file")
-	portFlag := flag.String("port", "", "Server port")
-	debugFlag := flag.Bool("debug", false, "Enable debug logging")
-	userConfigPathFlag := flag.String("users", "", "Path to users.yaml")
-	pipelineConfigPathFlag := flag.String("pipelines", "", "Path to pipelines.json")
-	llmConfigPathFlag := flag.String("llm", "", "Path to llm.yaml")
-	historyPathFlag := flag.String("history", "", "Path to history.jsonl")
-	flag.Parse()
+// configFlags 是启动服务和各子命令共用的命令行参数
+// 将默认值设置为空字符串，以便优先使用配置文件中的值
+type configFlags struct {
+	configPath         *string
+	port               *string
+	debug              *bool
+	userConfigPath     *string
+	pipelineConfigPath *string
+	llmConfigPath      *string
+	historyPath        *string
+}
 
+// registerConfigFlags 在 FlagSet 上注册配置相关的参数
+func registerConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		configPath:         fs.String("config", "configs/server.yaml", "Path to server config file"),
+		port:               fs.String("port", "", "Server port"),
+		debug:              fs.Bool("debug", false, "Enable debug logging"),
+		userConfigPath:     fs.String("users", "", "Path to users.yaml"),
+		pipelineConfigPath: fs.String("pipelines", "", "Path to pipeline config (.json or .yaml)"),
+		llmConfigPath:      fs.String("llm", "", "Path to LLM config (.yaml or .json)"),
+		historyPath:        fs.String("history", "", "Path to history.jsonl"),
+	}
+}
+
+// load 生成服务器配置，优先级：命令行参数 > 配置文件 > 默认值
+// 配置文件加载失败时仍返回由默认值和命令行参数组成的配置，同时返回错误，由调用方决定如何处理
+func (f *configFlags) load() (*ServerConfig, error) {
 	// 1. 初始化默认值
 	serverCfg := &ServerConfig{}
 	serverCfg.Server.Port = "8080"
@@ -78,7 +86,8 @@ func InitServerConfig() *ServerConfig {
 	serverCfg.Paths.History = "data/history.jsonl"
 
 	// 2. 尝试加载配置文件
-	if loadedCfg, err := loadServerConfig(*configPath); err == nil {
+	loadedCfg, loadErr := loadServerConfig(*f.configPath)
+	if loadErr == nil {
 		// 如果文件存在且加载成功，覆盖默认值
 		if loadedCfg.Server.Port != "" {
 			serverCfg.Server.Port = loadedCfg.Server.Port
@@ -99,32 +108,42 @@ func InitServerConfig() *ServerConfig {
 		if loadedCfg.Paths.History != "" {
 			serverCfg.Paths.History = loadedCfg.Paths.History
 		}
-	} else {
-		// 只有当用户显式指定了配置文件但加载失败时才报错，
-		// 或者如果默认文件不存在，我们就不报错，直接使用硬编码默认值
-		// 这里简化处理：只打印日志
-		log.Printf("Info: Could not load config file '%s': %v. Using defaults or flags.", *configPath, err)
 	}
 
 	// 3. 应用命令行参数 (优先级最高)
-	if *portFlag != "" {
-		serverCfg.Server.Port = *portFlag
+	if *f.port != "" {
+		serverCfg.Server.Port = *f.port
 	}
-	if *debugFlag {
+	if *f.debug {
 		serverCfg.Server.Debug = true
 	}
-	if *userConfigPathFlag != "" {
-		serverCfg.Paths.Users = *userConfigPathFlag
+	if *f.userConfigPath != "" {
+		serverCfg.Paths.Users = *f.userConfigPath
 	}
-	if *pipelineConfigPathFlag != "" {
-		serverCfg.Paths.Pipelines = *pipelineConfigPathFlag
+	if *f.pipelineConfigPath != "" {
+		serverCfg.Paths.Pipelines = *f.pipelineConfigPath
 	}
-	if *llmConfigPathFlag != "" {
-		serverCfg.Paths.LLM = *llmConfigPathFlag
+	if *f.llmConfigPath != "" {
+		serverCfg.Paths.LLM = *f.llmConfigPath
 	}
-	if *historyPathFlag != "" {
-		serverCfg.Paths.History = *historyPathFlag
+	if *f.historyPath != "" {
+		serverCfg.Paths.History = *f.historyPath
+	}
+
+	return serverCfg, loadErr
+}
+
+// InitServerConfig 初始化服务器配置，优先级：命令行参数 > 配置文件 > 默认值
+func InitServerConfig() *ServerConfig {
+	flags := registerConfigFlags(flag.CommandLine)
+	flag.Parse()
+
+	serverCfg, err := flags.load()
+	if err != nil {
+		// 只有当用户显式指定了配置文件但加载失败时才报错，
+		// 或者如果默认文件不存在，我们就不报错，直接使用硬编码默认值
+		// 这里简化处理：只打印日志
+		log.Printf("Info: Could not load config file '%s': %v. Using defaults or flags.", *flags.configPath, err)
 	}
-	
 	return serverCfg
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"recommend_engine/internal/workflow"
+)
+
+// runGraph 实现 `recommend graph` 子命令
+// 将 Pipeline 配置渲染为 DOT 或 Mermaid 流程图，只读取配置文件，不构建节点
+func runGraph(args []string) int {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	flags := registerConfigFlags(fs)
+	scene := fs.String("scene", "", "Scene to render; renders every scene when empty")
+	format := fs.String("format", workflow.GraphMermaid, "Output format: dot or mermaid")
+	tracePath := fs.String("trace", "", "JSON file with a trace (output of `run --format json` or a debug=true response) to show node latency")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: recommend graph [--scene <scene>] [--format dot|mermaid] [flags]")
+		fmt.Fprintln(fs.Output(), "\nRender pipelines as Graphviz DOT or Mermaid diagrams.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 || (*format != workflow.GraphDOT && *format != workflow.GraphMermaid) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	serverCfg, _ := flags.load()
+	globalCfg, err := workflow.LoadGlobalConfig(serverCfg.Paths.Pipelines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitProblems
+	}
+
+	var trace *workflow.Span
+	if *tracePath != "" {
+		if trace, err = readTraceFile(*tracePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitProblems
+		}
+	}
+
+	scenes := []string{*scene}
+	if *scene == "" {
+		scenes = scenes[:0]
+		for name := range globalCfg.Pipelines {
+			scenes = append(scenes, name)
+		}
+		sort.Strings(scenes)
+	}
+
+	for i, name := range scenes {
+		cfg, ok := globalCfg.Pipelines[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: scene '%s' not found in %s\n", name, serverCfg.Paths.Pipelines)
+			return exitProblems
+		}
+		// 轨迹只属于一个场景
+		var sceneTrace *workflow.Span
+		if trace != nil && trace.Name == name {
+			sceneTrace = trace
+		}
+		graph, err := workflow.RenderGraph(name, cfg, *format, sceneTrace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitProblems
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(graph)
+	}
+	return exitOK
+}
+
+// readTraceFile 读取轨迹文件，支持单独的 Span 或带有 trace 字段的响应
+func readTraceFile(path string) (*workflow.Span, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trace file: %w", err)
+	}
+	var wrapped struct {
+		Trace *workflow.Span `json:"trace"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("failed to parse trace file %s: %w", path, err)
+	}
+	if wrapped.Trace != nil {
+		return wrapped.Trace, nil
+	}
+	var span workflow.Span
+	if err := json.Unmarshal(data, &span); err != nil || span.Name == "" {
+		return nil, fmt.Errorf("no trace found in %s", path)
+	}
+	return &span, nil
+}
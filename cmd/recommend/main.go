@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"recommend_engine/internal/history"
@@ -12,7 +17,22 @@ import (
 	"recommend_engine/internal/workflow"
 )
 
+// shutdownTimeout 优雅退出时等待进行中的请求和异步任务的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "run":
+			os.Exit(runPipeline(os.Args[2:]))
+		case "graph":
+			os.Exit(runGraph(os.Args[2:]))
+		}
+	}
+
 	// 1. 初始化并加载配置
 	serverCfg := InitServerConfig()
 
@@ -67,13 +87,49 @@ func main() {
 		log.Fatalf("Failed to init engine: %v", err)
 	}
 
+	// 监听 Pipeline 配置变化并热更新，也可以通过 SIGHUP 手动触发
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go engine.Watch(watchCtx, 5*time.Second)
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGHUP)
+		for range sigCh {
+			if err := engine.Reload(); err != nil {
+				log.Printf("Error: Failed to reload pipeline config, keeping previous pipelines: %v", err)
+			} else {
+				log.Println("Pipeline config reloaded on SIGHUP")
+			}
+		}
+	}()
+
 	// 7. 初始化 Task Manager
 	taskManager := taskpkg.NewManager()
 
 	// 8. 启动 HTTP Server
 	srv := server.NewServer(userProvider, engine, historyStore, taskManager)
-	log.Printf("Starting HTTP server on port %s...", serverCfg.Server.Port)
-	if err := srv.Run(":" + serverCfg.Server.Port); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	go func() {
+		log.Printf("Starting HTTP server on port %s...", serverCfg.Server.Port)
+		if err := srv.Run(":" + serverCfg.Server.Port); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// 9. 收到 SIGINT / SIGTERM 后优雅退出：
+	// 先停止接收请求并等待进行中的请求，再关闭引擎 (等待异步任务结束并释放节点资源)
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	sig := <-stopCh
+	log.Printf("Received %v, shutting down...", sig)
+	stopWatch()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Warning: HTTP server shutdown: %v", err)
+	}
+	if err := engine.Close(ctx); err != nil {
+		log.Printf("Warning: engine shutdown: %v", err)
 	}
+	log.Println("Shutdown complete")
 }
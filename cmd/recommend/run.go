@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"recommend_engine/internal/history"
+	"recommend_engine/internal/logger"
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/user"
+	"recommend_engine/internal/workflow"
+)
+
+// runResult 是 run 子命令 JSON 格式的输出
+type runResult struct {
+	Scene string         `json:"scene"`
+	User  string         `json:"user"`
+	Items []*model.Item  `json:"items"`
+	Error string         `json:"error,omitempty"`
+	Trace *workflow.Span `json:"trace,omitempty"`
+
+	Experiments map[string]string `json:"experiments,omitempty"`
+}
+
+// runPipeline 实现 `recommend run` 子命令
+// 使用与启动服务相同的 Registry 和 Engine，在终端中为指定用户执行一次 Pipeline。
+// 默认不写入历史记录 (dry-run)，通过 --save-history 写入。
+func runPipeline(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	flags := registerConfigFlags(fs)
+	scene := fs.String("scene", "", "Scene (pipeline) to run, e.g. music")
+	userID := fs.String("user", "", "User ID from users.yaml")
+	favoritesFile := fs.String("favorites-file", "", "JSON file with favorites, either [\"a\", \"b\"] or {\"favorites\": [...]}; defaults to the user's favorites in users.yaml")
+	format := fs.String("format", "table", "Output format: table or json")
+	saveHistory := fs.Bool("save-history", false, "Write recommended items to the history store (default is dry-run)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: recommend run --scene <scene> --user <user_id> [flags]")
+		fmt.Fprintln(fs.Output(), "\nRun a pipeline once for a user and print the candidates and the trace.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *scene == "" || *userID == "" || fs.NArg() > 0 || (*format != "table" && *format != "json") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	serverCfg, err := flags.load()
+	if err != nil {
+		logger.Info("Could not load config file '%s': %v. Using defaults or flags.", *flags.configPath, err)
+	}
+	logger.SetDebug(serverCfg.Server.Debug)
+
+	u, err := loadRunUser(serverCfg.Paths.Users, *userID, *favoritesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitProblems
+	}
+
+	store, err := openRunHistory(serverCfg.Paths.History, *saveHistory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitProblems
+	}
+
+	llmCfg, err := loadLLMConfig(serverCfg.Paths.LLM)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load llm config: %v\n", err)
+		return exitProblems
+	}
+	registry := RegisterNodes(llmCfg, serverCfg.Paths.LLM, store)
+	engine, err := workflow.NewEngine(serverCfg.Paths.Pipelines, registry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to init engine: %v\n", err)
+		return exitProblems
+	}
+	defer engine.Close(context.Background())
+
+	// Ctrl+C 取消本次执行
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	wfCtx := workflow.NewContext(ctx, u.ID, u)
+	wfCtx.Config = map[string]interface{}{"domain": *scene}
+	runErr := engine.Run(wfCtx, *scene)
+
+	result := runResult{
+		Scene: *scene,
+		User:  u.ID,
+		Items: wfCtx.GetCandidates(),
+		Trace: wfCtx.Trace(),
+
+		Experiments: wfCtx.Experiments(),
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	} else if *saveHistory && len(result.Items) > 0 {
+		names := make([]string, 0, len(result.Items))
+		for _, item := range result.Items {
+			names = append(names, item.Name)
+		}
+		if err := store.SaveHistory(u.ID, *scene, names, result.Experiments); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save history: %v\n", err)
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		printRunTable(os.Stdout, result)
+	}
+
+	if runErr != nil {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// loadRunUser 从 users.yaml 获取用户，favoritesFile 不为空时使用其中的收藏列表
+func loadRunUser(usersPath, userID, favoritesFile string) (*model.User, error) {
+	provider, err := user.NewStaticProvider(usersPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init user provider: %w", err)
+	}
+	u, err := provider.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与 HTTP 接口一致，构建请求级的用户对象
+	requestUser := &model.User{
+		ID:        u.ID,
+		Name:      u.Name,
+		Token:     u.Token,
+		Favorites: u.Favorites,
+	}
+	if favoritesFile != "" {
+		favorites, err := readFavoritesFile(favoritesFile)
+		if err != nil {
+			return nil, err
+		}
+		requestUser.Favorites = favorites
+	}
+	return requestUser, nil
+}
+
+// readFavoritesFile 读取收藏列表，支持 JSON 数组或与请求体相同的 {"favorites": [...]}
+func readFavoritesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read favorites file: %w", err)
+	}
+
+	var favorites []string
+	if err := json.Unmarshal(data, &favorites); err == nil {
+		return favorites, nil
+	}
+	var body struct {
+		Favorites []string `json:"favorites"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to parse favorites file %s: expected a JSON array or {\"favorites\": [...]}", path)
+	}
+	return body.Favorites, nil
+}
+
+// openRunHistory 打开历史存储
+// dry-run 模式下仍然读取已有的历史记录，保证 filter_history 的行为与线上一致，但不会写入或创建文件
+func openRunHistory(path string, save bool) (history.Store, error) {
+	if save {
+		store, err := history.NewFileStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init history store: %w", err)
+		}
+		return store, nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nopHistoryStore{}, nil
+	}
+	store, err := history.NewFileStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init history store: %w", err)
+	}
+	return readOnlyHistoryStore{store}, nil
+}
+
+// readOnlyHistoryStore 丢弃所有写入，用于 dry-run
+type readOnlyHistoryStore struct {
+	history.Store
+}
+
+func (readOnlyHistoryStore) SaveHistory(userID string, domain string, items []string, experiments map[string]string) error {
+	return nil
+}
+
+func (readOnlyHistoryStore) Cleanup(days int) error {
+	return nil
+}
+
+// printRunTable 以表格形式输出候选集和执行轨迹
+func printRunTable(w io.Writer, result runResult) {
+	fmt.Fprintf(w, "Scene: %s  User: %s  Items: %d\n", result.Scene, result.User, len(result.Items))
+	if len(result.Experiments) > 0 {
+		names := make([]string, 0, len(result.Experiments))
+		for name := range result.Experiments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "Experiment: %s  Variant: %s\n", name, result.Experiments[name])
+		}
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "Error: %s\n", result.Error)
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tNAME\tSCORE\tSOURCE")
+	for i, item := range result.Items {
+		fmt.Fprintf(tw, "%d\t%s\t%.2f\t%s\n", i+1, item.Name, item.Score, item.Source)
+	}
+	tw.Flush()
+
+	if result.Trace == nil {
+		return
+	}
+	fmt.Fprintln(w, "\nTrace:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tTYPE\tSTATUS\tDURATION\tCANDIDATES\tERROR")
+	printSpan(tw, result.Trace, 0)
+	tw.Flush()
+}
+
+func printSpan(w io.Writer, span *workflow.Span, depth int) {
+	fmt.Fprintf(w, "%s%s\t%s\t%s\t%.1fms\t%d -> %d\t%s\n",
+		strings.Repeat("  ", depth), span.Name, span.Type, span.Status,
+		span.DurationMs, span.CandidatesBefore, span.CandidatesAfter, span.Error)
+	for _, child := range span.Children {
+		printSpan(w, child, depth+1)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"recommend_engine/internal/user"
+	"recommend_engine/internal/workflow"
+)
+
+// validate 子命令的退出码
+const (
+	exitOK       = 0 // 配置没有问题 (可能有警告)
+	exitProblems = 1 // 发现至少一个问题
+	exitUsage    = 2 // 命令行参数错误
+)
+
+// sceneNamePattern 是可以出现在 /api/v1/recommend/:scene 中的场景名
+var sceneNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// validationReport 收集校验过程中发现的所有问题
+type validationReport struct {
+	errors   []string
+	warnings []string
+}
+
+func (r *validationReport) errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *validationReport) warnf(format string, args ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// print 输出报告，返回对应的退出码
+func (r *validationReport) print(w io.Writer) int {
+	for _, msg := range r.errors {
+		fmt.Fprintf(w, "ERROR  %s\n", msg)
+	}
+	for _, msg := range r.warnings {
+		fmt.Fprintf(w, "WARN   %s\n", msg)
+	}
+	if len(r.errors) > 0 {
+		fmt.Fprintf(w, "\n%d problem(s), %d warning(s)\n", len(r.errors), len(r.warnings))
+		return exitProblems
+	}
+	fmt.Fprintf(w, "Configuration OK (%d warning(s))\n", len(r.warnings))
+	return exitOK
+}
+
+// runValidate 实现 `recommend validate` 子命令
+// 通过与启动服务相同的代码加载所有配置，不发起任何网络请求，也不会创建历史文件，
+// 一次性报告所有问题。退出码：0 没有问题，1 发现问题，2 参数错误。
+func runValidate(args []string) int {
+	return validateConfigs(args, os.Stdout, os.Stderr)
+}
+
+// validateConfigs 校验配置，报告写入 stdout，参数错误和用法写入 stderr
+func validateConfigs(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	flags := registerConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: recommend validate [flags]")
+		fmt.Fprintln(fs.Output(), "\nCheck server, user, LLM and pipeline configs without starting the server.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return exitUsage
+	}
+
+	report := &validationReport{}
+	serverCfg, err := flags.load()
+	if err != nil {
+		// 默认的配置文件不存在时使用默认值，显式指定的配置文件必须能够加载
+		explicit := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				explicit = true
+			}
+		})
+		if explicit {
+			report.errorf("%s: %v", *flags.configPath, err)
+		} else {
+			report.warnf("%s: %v (using defaults)", *flags.configPath, err)
+		}
+	}
+
+	validateUsers(report, serverCfg.Paths.Users)
+	llmCfg := validateLLM(report, serverCfg.Paths.LLM)
+	validatePipelines(report, serverCfg.Paths.Pipelines, serverCfg.Paths.LLM, llmCfg)
+
+	return report.print(stdout)
+}
+
+// validateUsers 检查用户配置，包括重复的 ID 和 Token
+func validateUsers(report *validationReport, path string) {
+	_, err := user.NewStaticProvider(path)
+	if err == nil {
+		return
+	}
+	var cfgErr *user.ConfigError
+	if errors.As(err, &cfgErr) {
+		for _, problem := range cfgErr.Problems {
+			report.errorf("%s: %s", path, problem)
+		}
+		return
+	}
+	report.errorf("%s: %v", path, err)
+}
+
+// validateLLM 检查 LLM 配置中每个模型的必填项
+// 占位符 API Key 在检查 Pipeline 时处理，因为只有被引用的配置才会导致请求失败
+func validateLLM(report *validationReport, path string) *LLMGlobalConfig {
+	llmCfg, err := loadLLMConfig(path)
+	if err != nil {
+		report.errorf("%s: %v", path, err)
+		return &LLMGlobalConfig{}
+	}
+
+	keys := make([]string, 0, len(llmCfg.LLMs))
+	for key := range llmCfg.LLMs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cred := llmCfg.LLMs[key]
+		if u, err := url.Parse(cred.ChatEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			report.errorf("%s: llms.%s.chat_endpoint: invalid URL %q", path, key, cred.ChatEndpoint)
+		}
+		if cred.Model == "" {
+			report.errorf("%s: llms.%s.model: required field is missing", path, key)
+		}
+	}
+	return llmCfg
+}
+
+// validatePipelines 通过 Registry 构建所有 Pipeline，并检查跨文件引用
+// 不调用节点的 Init，不会启动 exec 进程或访问 remote_http 等外部服务
+func validatePipelines(report *validationReport, path, llmPath string, llmCfg *LLMGlobalConfig) {
+	registry := RegisterNodes(llmCfg, llmPath, nopHistoryStore{})
+	if warnings, err := workflow.ValidateConfig(path, registry); err == nil {
+		for _, w := range warnings {
+			report.warnf("%s: %v", path, w)
+		}
+	} else {
+		var errs workflow.ConfigErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				report.errorf("%s: %v", path, e)
+			}
+		} else {
+			report.errorf("%s: %v", path, err)
+		}
+	}
+
+	// 以下检查需要原始配置，文件无法解析时 NewEngine 已经报告过
+	globalCfg, err := workflow.LoadGlobalConfig(path)
+	if err != nil {
+		return
+	}
+
+	if len(globalCfg.Pipelines) == 0 {
+		report.errorf("%s: no pipelines defined", path)
+	}
+	scenes := make([]string, 0, len(globalCfg.Pipelines))
+	for scene := range globalCfg.Pipelines {
+		scenes = append(scenes, scene)
+	}
+	sort.Strings(scenes)
+
+	reachable := reachableScenes(globalCfg.Pipelines)
+	referenced := make(map[string]bool)
+	for _, scene := range scenes {
+		if !reachable[scene] {
+			report.errorf("%s: pipelines.%s: scene is unreachable: its name cannot be requested via /api/v1/recommend/:scene and no reachable pipeline includes it", path, scene)
+		}
+		pipeCfg := globalCfg.Pipelines[scene]
+		if pipeCfg.Experiment == nil {
+			if len(pipeCfg.Nodes) == 0 {
+				report.errorf("%s: pipelines.%s: pipeline has no nodes", path, scene)
+			}
+		} else {
+			for i, v := range pipeCfg.Experiment.Variants {
+				if len(v.Nodes) == 0 {
+					report.errorf("%s: pipelines.%s.experiment.variants[%d]: variant '%s' has no nodes", path, scene, i, v.Name)
+				}
+				if v.Weight == 0 {
+					report.warnf("%s: pipelines.%s.experiment.variants[%d]: variant '%s' has weight 0 and receives no traffic", path, scene, i, v.Name)
+				}
+			}
+		}
+		for _, nodes := range pipeCfg.NodeLists() {
+			collectLLMKeys(nodes, referenced)
+		}
+	}
+
+	// 被 Pipeline 引用的模型使用占位符 API Key 时，请求必然失败
+	keys := make([]string, 0, len(llmCfg.LLMs))
+	for key := range llmCfg.LLMs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !isPlaceholder(llmCfg.LLMs[key].APIKey) {
+			continue
+		}
+		if referenced[key] {
+			report.errorf("%s: llms.%s.api_key: placeholder value %q", llmPath, key, llmCfg.LLMs[key].APIKey)
+		} else {
+			report.warnf("%s: llms.%s.api_key: placeholder value %q (not used by any pipeline)", llmPath, key, llmCfg.LLMs[key].APIKey)
+		}
+	}
+}
+
+// requestable 判断场景名能否通过 /api/v1/recommend/:scene 请求
+func requestable(scene string) bool {
+	return sceneNamePattern.MatchString(scene) && scene != "." && scene != ".."
+}
+
+// reachableScenes 返回可以被执行的场景
+// 能够直接请求的场景是入口，入口经由 pipeline 节点引用、影子流程 (直接或间接) 到达的场景同样可以执行，
+// 例如名称中含有 "/" 的公共子流程
+func reachableScenes(pipelines map[string]workflow.PipelineConfig) map[string]bool {
+	reachable := make(map[string]bool, len(pipelines))
+	var queue []string
+	for scene := range pipelines {
+		if requestable(scene) {
+			reachable[scene] = true
+			queue = append(queue, scene)
+		}
+	}
+	for len(queue) > 0 {
+		scene := queue[0]
+		queue = queue[1:]
+		pipeCfg := pipelines[scene]
+
+		var targets []string
+		for _, nodes := range pipeCfg.NodeLists() {
+			targets = collectPipelineRefs(nodes, targets)
+		}
+		if pipeCfg.Shadow != nil {
+			targets = append(targets, pipeCfg.Shadow.Pipeline)
+		}
+		for _, target := range targets {
+			if _, ok := pipelines[target]; ok && !reachable[target] {
+				reachable[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+	return reachable
+}
+
+// collectPipelineRefs 收集节点配置树中 pipeline 节点引用的场景名
+func collectPipelineRefs(cfgs []workflow.NodeConfig, refs []string) []string {
+	for _, cfg := range cfgs {
+		if cfg.Type == "pipeline" {
+			if scene, ok := cfg.Config["pipeline"].(string); ok {
+				refs = append(refs, scene)
+			}
+		}
+		refs = collectPipelineRefs(cfg.Nodes, refs)
+		refs = collectPipelineRefs(cfg.Default, refs)
+		for _, c := range cfg.Cases {
+			refs = collectPipelineRefs(c.Nodes, refs)
+		}
+	}
+	return refs
+}
+
+// collectLLMKeys 收集节点配置树中 recall_llm 节点引用的 llm_config_key
+func collectLLMKeys(cfgs []workflow.NodeConfig, keys map[string]bool) {
+	for _, cfg := range cfgs {
+		if cfg.Type == "recall_llm" {
+			if key, ok := cfg.Config["llm_config_key"].(string); ok {
+				keys[key] = true
+			}
+		}
+		collectLLMKeys(cfg.Nodes, keys)
+		collectLLMKeys(cfg.Default, keys)
+		for _, c := range cfg.Cases {
+			collectLLMKeys(c.Nodes, keys)
+		}
+	}
+}
+
+// isPlaceholder 判断 API Key 是否为空或示例配置中的占位符 (如 <your_api_key>)
+func isPlaceholder(key string) bool {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return true
+	}
+	if strings.HasPrefix(key, "<") && strings.HasSuffix(key, ">") {
+		return true
+	}
+	lower := strings.ToLower(key)
+	return strings.Contains(lower, "your_api_key") || strings.Contains(lower, "your-api-key")
+}
+
+// nopHistoryStore 是校验配置时使用的历史存储，不读写任何文件
+type nopHistoryStore struct{}
+
+func (nopHistoryStore) GetRecentHistory(userID string, domain string, days int) ([]string, error) {
+	return nil, nil
+}
+
+func (nopHistoryStore) SaveHistory(userID string, domain string, items []string, experiments map[string]string) error {
+	return nil
+}
+
+func (nopHistoryStore) Cleanup(days int) error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	validUsers = `users:
+  - {id: u1, token: sk-1}
+  - {id: u2, token: sk-2}
+`
+	validLLM = `llms:
+  doubao:
+    chat_endpoint: "https://llm.example.com/v1/chat/completions"
+    api_key: "sk-test"
+    model: "m1"
+  spare:
+    chat_endpoint: "https://llm.example.com/v1/chat/completions"
+    api_key: "<your_api_key>"
+    model: "m2"
+`
+	// common/tail 无法直接请求，但被 music 引用，因此可以执行
+	validPipelines = `{"pipelines": {
+		"music": {"nodes": [
+			{"name": "recall", "type": "recall_llm", "config": {"llm_config_key": "doubao", "count": 5}},
+			{"name": "tail", "type": "pipeline", "config": {"pipeline": "common/tail"}}
+		]},
+		"common/tail": {"nodes": [{"name": "rank", "type": "rank_simple", "config": {"limit": 10}}]}
+	}}`
+)
+
+func TestValidateCommand(t *testing.T) {
+	cases := map[string]struct {
+		args      []string // 追加在配置文件参数之后
+		users     string
+		llm       string
+		pipelines string
+		code      int
+		want      []string // 输出中应当包含的内容
+	}{
+		"valid": {
+			code: exitOK,
+			want: []string{"WARN   ", `llms.spare.api_key: placeholder value "<your_api_key>" (not used by any pipeline)`, "Configuration OK (1 warning(s))"},
+		},
+		"unknown node type": {
+			pipelines: `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "nope"}]}}}`,
+			code:      exitProblems,
+			want:      []string{"ERROR  ", "pipelines.music.nodes[0]: unknown node type: nope"},
+		},
+		"unknown llm key": {
+			pipelines: `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "recall_llm", "config": {"llm_config_key": "missing"}}]}}}`,
+			code:      exitProblems,
+			want:      []string{"pipelines.music.nodes[0].config.llm_config_key: llm config key 'missing' not found in"},
+		},
+		"placeholder api key in use": {
+			pipelines: `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "recall_llm", "config": {"llm_config_key": "spare"}}]}}}`,
+			code:      exitProblems,
+			want:      []string{`llms.spare.api_key: placeholder value "<your_api_key>"`},
+		},
+		"duplicate token": {
+			users: "users:\n  - {id: u1, token: sk-1}\n  - {id: u2, token: sk-1}\n",
+			code:  exitProblems,
+			want:  []string{"users[1]: token already used by user 'u1'"},
+		},
+		"unreachable scenes": {
+			// internal/chain 只被同样无法到达的 internal/orphan 引用
+			pipelines: `{"pipelines": {
+				"music": {"nodes": [{"name": "recall", "type": "recall_llm", "config": {"llm_config_key": "doubao"}}]},
+				"internal/orphan": {"nodes": [{"name": "tail", "type": "pipeline", "config": {"pipeline": "internal/chain"}}]},
+				"internal/chain": {"nodes": [{"name": "recall", "type": "recall_llm", "config": {"llm_config_key": "doubao"}}]}
+			}}`,
+			code: exitProblems,
+			want: []string{
+				"pipelines.internal/chain: scene is unreachable",
+				"pipelines.internal/orphan: scene is unreachable",
+				"2 problem(s)",
+			},
+		},
+		"reachable through shadow": {
+			pipelines: `{"pipelines": {
+				"music": {"shadow": {"pipeline": "music/v2", "sample_rate": 0.1}, "nodes": [{"name": "recall", "type": "recall_llm", "config": {"llm_config_key": "doubao"}}]},
+				"music/v2": {"nodes": [{"name": "recall", "type": "recall_llm", "config": {"llm_config_key": "doubao"}}]}
+			}}`,
+			code: exitOK,
+			want: []string{"Configuration OK"},
+		},
+		"unexpected argument": {
+			args: []string{"extra"},
+			code: exitUsage,
+			want: []string{"unexpected arguments: [extra]"},
+		},
+	}
+
+	for name, tc := range cases {
+		dir := t.TempDir()
+		write := func(file, content, fallback string) string {
+			if content == "" {
+				content = fallback
+			}
+			path := filepath.Join(dir, file)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+		args := []string{
+			"--config", write("server.yaml", "server: {port: \"8080\"}\n", ""),
+			"--users", write("users.yaml", tc.users, validUsers),
+			"--llm", write("llm.yaml", tc.llm, validLLM),
+			"--pipelines", write("pipelines.json", tc.pipelines, validPipelines),
+		}
+		args = append(args, tc.args...)
+
+		var stdout, stderr bytes.Buffer
+		code := validateConfigs(args, &stdout, &stderr)
+		output := stdout.String() + stderr.String()
+		if code != tc.code {
+			t.Errorf("%s: expected exit code %d, got %d\n%s", name, tc.code, code, output)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(output, want) {
+				t.Errorf("%s: expected output containing %q, got:\n%s", name, want, output)
+			}
+		}
+	}
+}
+
+func TestValidateMissingExplicitConfig(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := validateConfigs([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, &stdout, &stderr)
+	if code != exitProblems || !strings.Contains(stdout.String(), "missing.yaml") {
+		t.Errorf("expected exit code %d reporting the missing config, got %d:\n%s", exitProblems, code, stdout.String())
+	}
+}
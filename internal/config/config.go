@@ -0,0 +1,220 @@
+// Package config 负责读取 YAML / JSON 配置文件，并展开其中字符串值的环境变量引用
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// refPattern 匹配 $${...} (转义) 以及 ${NAME} / ${NAME:-default}
+var refPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Load 读取配置文件，按扩展名解析为 YAML 或 JSON，再展开字符串值中的环境变量引用
+// 展开发生在解析之后，注释中的引用不会被展开，变量的值也不会改变文件结构
+func Load(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = loadYAML(data, out)
+	case ".json":
+		err = loadJSON(data, out)
+	default:
+		return Unmarshal(path, data, out)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Unmarshal 按文件扩展名解析配置：.yaml / .yml 为 YAML，.json 为 JSON
+// 不展开环境变量引用
+func Unmarshal(path string, data []byte, out interface{}) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, out)
+	case ".json":
+		return json.Unmarshal(data, out)
+	default:
+		return fmt.Errorf("unsupported config format %q (expected .yaml, .yml or .json)", filepath.Ext(path))
+	}
+}
+
+// loadYAML 展开 YAML 中所有字符串标量 (不包括映射的键) 的引用
+// 未加引号的标量展开后按 YAML 规则重新识别类型，例如 "limit: ${LIMIT}" 可以得到整数；
+// 加引号的标量始终是字符串
+func loadYAML(data []byte, out interface{}) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	var e expander
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				walk(n.Content[i])
+			}
+		case yaml.SequenceNode, yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c)
+			}
+		case yaml.ScalarNode:
+			// 别名指向的锚点在树中只出现一次，不会被重复展开
+			if n.ShortTag() != "!!str" {
+				return
+			}
+			expanded := e.expand(n.Value)
+			if expanded == n.Value {
+				return
+			}
+			n.Value = expanded
+			if n.Style == 0 {
+				n.Tag = ""
+			}
+		}
+	}
+	walk(&doc)
+	if err := e.err(); err != nil {
+		return err
+	}
+	return doc.Decode(out)
+}
+
+// loadJSON 展开 JSON 中所有字符串值 (不包括对象的键) 的引用，JSON 中的字符串展开后仍是字符串
+func loadJSON(data []byte, out interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	var e expander
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				v[k] = walk(item)
+			}
+		case []interface{}:
+			for i, item := range v {
+				v[i] = walk(item)
+			}
+		case string:
+			return e.expand(v)
+		}
+		return v
+	}
+	doc = walk(doc)
+	if err := e.err(); err != nil {
+		return err
+	}
+
+	expanded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(expanded, out)
+}
+
+// Expand 展开文本中的环境变量引用
+//
+//   - ${NAME}            取环境变量 NAME 的值；未设置时报错
+//   - ${NAME:-default}   NAME 未设置或为空时使用 default
+//   - $${...}            转义，输出字面量 ${...}
+//
+// NAME 未设置时，如果设置了 NAME_FILE，则读取该文件的内容 (去掉首尾空白) 作为值，
+// 便于从挂载的 Secret 文件中读取 API Key 等敏感信息。
+// 值按原样替换到文本中。所有未设置的变量会在一个错误中一并报告。
+func Expand(s string) (string, error) {
+	var e expander
+	result := e.expand(s)
+	if err := e.err(); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// expander 展开多个字符串中的引用，并汇总其中所有未设置的变量
+type expander struct {
+	missing []string
+	fileErr error
+}
+
+func (e *expander) expand(s string) string {
+	return refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := refPattern.FindStringSubmatch(ref)
+		name, hasDefault, def := m[1], m[2] != "", m[3]
+
+		value, ok, err := lookup(name)
+		if err != nil && e.fileErr == nil {
+			e.fileErr = err
+		}
+		if ok && (value != "" || !hasDefault) {
+			return value
+		}
+		if hasDefault {
+			return def
+		}
+		e.missing = append(e.missing, name)
+		return ref
+	})
+}
+
+func (e *expander) err() error {
+	if e.fileErr != nil {
+		return e.fileErr
+	}
+	if len(e.missing) > 0 {
+		sort.Strings(e.missing)
+		return fmt.Errorf("environment variables not set: %s", strings.Join(dedupe(e.missing), ", "))
+	}
+	return nil
+}
+
+// lookup 查找环境变量，未设置时尝试读取 NAME_FILE 指向的文件
+func lookup(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+func dedupe(sorted []string) []string {
+	result := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RE_API_KEY", "sk-123")
+	t.Setenv("RE_EMPTY", "")
+	t.Setenv("RE_TOKEN_FILE", secret)
+
+	cases := map[string]string{
+		"key: ${RE_API_KEY}":           "key: sk-123",
+		"key: ${RE_UNSET:-fallback}":   "key: fallback",
+		"key: ${RE_EMPTY:-fallback}":   "key: fallback",
+		"key: '${RE_EMPTY}'":           "key: ''",
+		"key: ${RE_TOKEN}":             "key: from-file",
+		"prompt: $${NOT_EXPANDED}":     "prompt: ${NOT_EXPANDED}",
+		"price: $5 and ${RE_API_KEY}x": "price: $5 and sk-123x",
+	}
+	for in, want := range cases {
+		got, err := Expand(in)
+		if err != nil {
+			t.Errorf("Expand(%q) failed: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expand(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	_, err := Expand("a: ${RE_MISSING_B}\nb: ${RE_MISSING_A}\nc: ${RE_MISSING_B}")
+	if err == nil || !strings.Contains(err.Error(), "environment variables not set: RE_MISSING_A, RE_MISSING_B") {
+		t.Errorf("expected error listing missing variables, got %v", err)
+	}
+}
+
+func TestLoadByExtension(t *testing.T) {
+	type llm struct {
+		APIKey string `yaml:"api_key" json:"api_key"`
+		Count  int    `yaml:"count" json:"count"`
+	}
+	t.Setenv("RE_API_KEY", "sk-123")
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"llm.yaml": "api_key: ${RE_API_KEY}\ncount: ${RE_COUNT:-50}\n",
+		"llm.yml":  "api_key: ${RE_API_KEY}\ncount: 50\n",
+		"llm.json": `{"api_key": "${RE_API_KEY}", "count": 50}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var cfg llm
+		if err := Load(path, &cfg); err != nil {
+			t.Errorf("%s: Load failed: %v", name, err)
+			continue
+		}
+		if cfg.APIKey != "sk-123" || cfg.Count != 50 {
+			t.Errorf("%s: unexpected config %+v", name, cfg)
+		}
+	}
+
+	path := filepath.Join(dir, "llm.toml")
+	os.WriteFile(path, []byte(""), 0644)
+	if err := Load(path, &llm{}); err == nil {
+		t.Error("expected error for unsupported extension")
+	}
+}
+
+func TestLoadExpandsStringValuesOnly(t *testing.T) {
+	type server struct {
+		Name   string            `yaml:"name" json:"name"`
+		Port   int               `yaml:"port" json:"port"`
+		Prompt string            `yaml:"prompt" json:"prompt"`
+		Quoted string            `yaml:"quoted" json:"quoted"`
+		Tags   []string          `yaml:"tags" json:"tags"`
+		Labels map[string]string `yaml:"labels" json:"labels"`
+	}
+	t.Setenv("RE_NAME", "line1\nline2: injected")
+	t.Setenv("RE_PORT", "8080")
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"server.yaml": `# 注释中的 ${RE_UNSET_IN_COMMENT} 不会被展开
+name: ${RE_NAME}
+port: ${RE_PORT}
+prompt: "use $${VAR} literally"
+quoted: "${RE_PORT}"
+tags: [a, "${RE_PORT}"]
+labels:
+  ${RE_UNSET_KEY}: ${RE_PORT:-x}
+`,
+		"server.json": `{"name": "${RE_NAME}", "port": 8080, "prompt": "use $${VAR} literally", "quoted": "${RE_PORT}",
+			"tags": ["a", "${RE_PORT}"], "labels": {"${RE_UNSET_KEY}": "${RE_PORT:-x}"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var cfg server
+		if err := Load(path, &cfg); err != nil {
+			t.Errorf("%s: Load failed: %v", name, err)
+			continue
+		}
+		// 含换行和冒号的值仍是同一个字符串，不会改变文件结构
+		if cfg.Name != "line1\nline2: injected" || cfg.Port != 8080 || cfg.Prompt != "use ${VAR} literally" || cfg.Quoted != "8080" {
+			t.Errorf("%s: unexpected config %+v", name, cfg)
+		}
+		if len(cfg.Tags) != 2 || cfg.Tags[1] != "8080" || cfg.Labels["${RE_UNSET_KEY}"] != "8080" {
+			t.Errorf("%s: unexpected collections %+v", name, cfg)
+		}
+	}
+
+	path := filepath.Join(dir, "missing.yaml")
+	os.WriteFile(path, []byte("a: ${RE_MISSING_B}\nb:\n  - ${RE_MISSING_A}\n"), 0644)
+	err := Load(path, &map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "environment variables not set: RE_MISSING_A, RE_MISSING_B") {
+		t.Errorf("expected error listing missing variables, got %v", err)
+	}
+}
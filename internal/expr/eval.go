@@ -0,0 +1,477 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type (
+	literalNode struct {
+		value interface{}
+	}
+	varNode struct {
+		name string
+	}
+	listNode struct {
+		elems []node
+	}
+	indexNode struct {
+		x, index node
+		column   int
+	}
+	unaryNode struct {
+		op     string
+		x      node
+		column int
+	}
+	binaryNode struct {
+		op          string
+		left, right node
+		column      int
+	}
+	ternaryNode struct {
+		cond, then, otherwise node
+		column                int
+	}
+	callNode struct {
+		name   string
+		fn     function
+		args   []node
+		column int
+	}
+)
+
+func (n *literalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *varNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return normalize(vars[n.name]), nil
+}
+
+func (n *listNode) eval(vars map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, len(n.elems))
+	for i, elem := range n.elems {
+		v, err := elem.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+// eval 访问映射的键或列表的下标，键不存在、下标越界或对 null 取值时得到 null
+func (n *indexNode) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch c := x.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, &Error{Column: n.column, Msg: fmt.Sprintf("map key must be a string, got %s", typeName(index))}
+		}
+		return normalize(c[key]), nil
+	case []interface{}:
+		f, ok := toNumber(index)
+		if !ok || f != math.Trunc(f) {
+			return nil, &Error{Column: n.column, Msg: fmt.Sprintf("list index must be an integer, got %s", typeName(index))}
+		}
+		i := int(f)
+		if i < 0 {
+			i += len(c)
+		}
+		if i < 0 || i >= len(c) {
+			return nil, nil
+		}
+		return normalize(c[i]), nil
+	}
+	return nil, &Error{Column: n.column, Msg: fmt.Sprintf("cannot index %s", typeName(x))}
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, err := truthy(x, n.column)
+		return !b, err
+	}
+	f, ok := toNumber(x)
+	if !ok {
+		return nil, &Error{Column: n.column, Msg: fmt.Sprintf("cannot negate %s", typeName(x))}
+	}
+	return -f, nil
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路求值
+	if n.op == "&&" || n.op == "||" {
+		l, err := truthy(left, n.column)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(right, n.column)
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, ok := compare(left, right)
+		if !ok {
+			return false, nil
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	case "in", "not in":
+		found, err := contains(right, left, n.column)
+		if err != nil {
+			return nil, err
+		}
+		return found == (n.op == "in"), nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			if rs, ok := right.(string); ok {
+				return ls + rs, nil
+			}
+		}
+	}
+	return arithmetic(n.op, left, right, n.column)
+}
+
+func (n *ternaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	cond, err := n.cond.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := truthy(cond, n.column)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return n.then.eval(vars)
+	}
+	return n.otherwise.eval(vars)
+}
+
+func (n *callNode) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, &Error{Column: n.column, Msg: fmt.Sprintf("%s(): %v", n.name, err)}
+	}
+	return v, nil
+}
+
+// truthy 将条件转换为布尔值：null 为 false，其余非布尔值返回错误
+func truthy(v interface{}, column int) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+	return false, &Error{Column: column, Msg: fmt.Sprintf("expected a boolean, got %s", typeName(v))}
+}
+
+func arithmetic(op string, left, right interface{}, column int) (interface{}, error) {
+	l, ok1 := toNumber(left)
+	r, ok2 := toNumber(right)
+	if !ok1 || !ok2 {
+		return nil, &Error{Column: column, Msg: fmt.Sprintf("cannot apply '%s' to %s and %s", op, typeName(left), typeName(right))}
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, &Error{Column: column, Msg: "division by zero"}
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, &Error{Column: column, Msg: "division by zero"}
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, &Error{Column: column, Msg: "unknown operator " + op}
+}
+
+// contains 判断 needle 是否在列表中、是否为映射的键或字符串的子串
+func contains(haystack, needle interface{}, column int) (bool, error) {
+	switch h := haystack.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, v := range h {
+			if equal(normalize(v), needle) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := needle.(string)
+		if !ok {
+			return false, nil
+		}
+		_, exists := h[key]
+		return exists, nil
+	case string:
+		s, ok := needle.(string)
+		return ok && strings.Contains(h, s), nil
+	}
+	return false, &Error{Column: column, Msg: fmt.Sprintf("'in' requires a list, map or string, got %s", typeName(haystack))}
+}
+
+func equal(a, b interface{}) bool {
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		return ok && na == nb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare 比较两个数字或两个字符串，类型不同时返回 false
+func compare(a, b interface{}) (int, bool) {
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case na < nb:
+			return -1, true
+		case na > nb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, ok1 := a.(string)
+	sb, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+// toNumber 将 JSON / YAML 解码可能得到的各种数字类型统一为 float64
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// normalize 将变量中的 []string 等常见类型转换为表达式使用的类型，数字统一为 float64
+func normalize(v interface{}) interface{} {
+	if n, ok := toNumber(v); ok {
+		return n
+	}
+	switch x := v.(type) {
+	case []string:
+		list := make([]interface{}, len(x))
+		for i, s := range x {
+			list[i] = s
+		}
+		return list
+	case map[string]string:
+		m := make(map[string]interface{}, len(x))
+		for k, s := range x {
+			m[k] = s
+		}
+		return m
+	}
+	return v
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	if _, ok := toNumber(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// function 是表达式中可以调用的函数，maxArgs 为 -1 表示不限个数
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"len": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return 0.0, nil
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("expected a string, list or map, got %s", typeName(args[0]))
+	}},
+	"lower":       stringFunc(strings.ToLower),
+	"upper":       stringFunc(strings.ToUpper),
+	"contains":    stringPredicate(strings.Contains),
+	"starts_with": stringPredicate(strings.HasPrefix),
+	"ends_with":   stringPredicate(strings.HasSuffix),
+	"min":         {1, -1, extremum(func(a, b float64) bool { return a < b })},
+	"max":         {1, -1, extremum(func(a, b float64) bool { return a > b })},
+	"abs": {1, 1, func(args []interface{}) (interface{}, error) {
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %s", typeName(args[0]))
+		}
+		return math.Abs(n), nil
+	}},
+	// number 将字符串转换为数字，无法转换或为 null 时得到 null
+	"number": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return n, nil
+		case nil:
+			return nil, nil
+		}
+		if n, ok := toNumber(args[0]); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("cannot convert %s to a number", typeName(args[0]))
+	}},
+	"string": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		if n, ok := toNumber(args[0]); ok {
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		}
+		return nil, fmt.Errorf("cannot convert %s to a string", typeName(args[0]))
+	}},
+}
+
+func stringFunc(fn func(string) string) function {
+	return function{1, 1, func(args []interface{}) (interface{}, error) {
+		switch s := args[0].(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return fn(s), nil
+		}
+		return nil, fmt.Errorf("expected a string, got %s", typeName(args[0]))
+	}}
+}
+
+func stringPredicate(fn func(s, sub string) bool) function {
+	return function{2, 2, func(args []interface{}) (interface{}, error) {
+		s, ok1 := args[0].(string)
+		sub, ok2 := args[1].(string)
+		if args[0] == nil {
+			return false, nil
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("expected strings, got %s and %s", typeName(args[0]), typeName(args[1]))
+		}
+		return fn(s, sub), nil
+	}}
+}
+
+func extremum(better func(a, b float64) bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		var result float64
+		for i, arg := range args {
+			n, ok := toNumber(arg)
+			if !ok {
+				return nil, fmt.Errorf("expected numbers, got %s", typeName(arg))
+			}
+			if i == 0 || better(n, result) {
+				result = n
+			}
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,117 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+var testVars = []string{"score", "source", "meta_data", "user", "tags"}
+
+func testEnv() map[string]interface{} {
+	return map[string]interface{}{
+		"score":  0.5,
+		"source": "doubao_recall_1",
+		"meta_data": map[string]interface{}{
+			"year":         float64(1985),
+			"release year": "1999",
+			"artist":       "Jay Chou",
+		},
+		"user": map[string]interface{}{"id": "alice", "favorites": []string{"晴天", "七里香"}},
+		"tags": []interface{}{"pop", "mandarin"},
+	}
+}
+
+func TestEval(t *testing.T) {
+	cases := map[string]interface{}{
+		`meta_data.year < 1990`:                                              true,
+		`source == 'doubao_recall_1' ? score * 1.2 : score`:                  0.6,
+		`score * 2 + 1`:                                                      2.0,
+		`-score + 10 % 3`:                                                    0.5,
+		`meta_data['release year']`:                                          "1999",
+		`number(meta_data['release year']) >= 1990`:                          true,
+		`meta_data.missing < 1990`:                                           false,
+		`meta_data.missing == null`:                                          true,
+		`meta_data.missing.deeper`:                                           nil,
+		`'pop' in tags && not ('rock' in tags)`:                              true,
+		`'rock' not in tags`:                                                 true,
+		`'晴天' in user.favorites`:                                             true,
+		`user.favorites[-1]`:                                                 "七里香",
+		`lower(meta_data.artist) == "jay chou"`:                              true,
+		`starts_with(source, 'doubao') or false`:                             true,
+		`max(score, 0.8, 0.1) - min(1, abs(-3))`:                             -0.19999999999999996,
+		`len(tags) + len(meta_data.artist)`:                                  10.0,
+		`'a' + string(1.5) + "\n"`:                                           "a1.5\n",
+		`score > 0.4 and score < 0.6 ? 'mid' : score > 0.6 ? 'high' : 'low'`: "mid",
+		`[1, 2, 3][1]`:                                                       2.0,
+		`'Chou' in meta_data.artist`:                                         true,
+	}
+	for src, want := range cases {
+		p, err := Compile(src, testVars)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", src, err)
+			continue
+		}
+		got, err := p.Eval(testEnv())
+		if err != nil {
+			t.Errorf("%s: eval failed: %v", src, err)
+			continue
+		}
+		if !equal(got, want) {
+			t.Errorf("%s: expected %v, got %v", src, want, got)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := map[string]string{
+		``:                   "column 1: empty expression",
+		`scroe > 1`:          "column 1: unknown variable 'scroe'",
+		`score >`:            "column 8: unexpected end of expression",
+		`score > 1)`:         `column 10: unexpected ")"`,
+		`(score > 1`:         "expected ')'",
+		`exec('rm -rf /')`:   "column 1: unknown function 'exec'",
+		`lower(source, 'x')`: "lower() takes 1 arguments, got 2",
+		`min()`:              "min() takes at least 1 arguments, got 0",
+		`source == 'abc`:     "column 11: unterminated string",
+		`score $ 1`:          `column 7: unexpected character '$'`,
+		`meta_data.`:         "expected a field name after '.'",
+		`score ? 1`:          "expected ':'",
+		strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100): "nested deeper than 64 levels",
+	}
+	for src, want := range cases {
+		_, err := Compile(src, testVars)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	cases := map[string]string{
+		`meta_data.missing * 2`: "column 19: cannot apply '*' to null and number",
+		`score / 0`:             "division by zero",
+		`source && true`:        "expected a boolean, got string",
+		`score[0]`:              "cannot index number",
+		`abs(source)`:           "abs(): expected a number, got string",
+		`1 in score`:            "'in' requires a list, map or string, got number",
+	}
+	for src, want := range cases {
+		p, err := Compile(src, testVars)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", src, err)
+			continue
+		}
+		if _, err := p.Eval(testEnv()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %v", src, want, err)
+		}
+	}
+
+	// 结果类型检查
+	p, _ := Compile(`source`, testVars)
+	if _, err := p.EvalBool(testEnv()); err == nil || !strings.Contains(err.Error(), "must evaluate to a boolean") {
+		t.Errorf("expected boolean result error, got %v", err)
+	}
+	if _, err := p.EvalNumber(testEnv()); err == nil || !strings.Contains(err.Error(), "must evaluate to a number") {
+		t.Errorf("expected number result error, got %v", err)
+	}
+}
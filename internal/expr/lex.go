@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind   tokenKind
+	text   string      // 运算符、标识符的原文
+	value  interface{} // 数字、字符串字面量的值
+	column int
+}
+
+// 按长度从长到短排列，保证最长匹配
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ",", ".",
+}
+
+// lex 将源码切分为 token，最后一个 token 总是 tokEOF
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		column := utf8.RuneCountInString(src[:i]) + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, &Error{Column: column, Msg: "invalid number " + strconv.Quote(src[i:j])}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[i:j], value: n, column: column})
+			i = j
+		case r == '\'' || r == '"':
+			s, n, err := lexString(src[i:], byte(r))
+			if err != nil {
+				return nil, &Error{Column: column, Msg: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokString, text: src[i : i+n], value: s, column: column})
+			i += n
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:j], column: column})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Column: column, Msg: "unexpected character " + strconv.QuoteRune(r)}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, column: column})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, column: utf8.RuneCountInString(src) + 1}), nil
+}
+
+// lexString 读取以 quote 开头的字符串字面量，返回值和消耗的字节数
+// 支持 \\ \' \" \n \t 转义
+func lexString(src string, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch c {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(src) {
+				break
+			}
+			i++
+			switch src[i] {
+			case '\\', '\'', '"':
+				b.WriteByte(src[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c in string", src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
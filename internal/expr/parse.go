@@ -0,0 +1,301 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// 二元运算符的优先级，数字越大结合越紧；三元运算符 ?: 的优先级最低，单独处理
+var precedence = map[string]int{
+	"||": 1, "or": 1,
+	"&&": 2, "and": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4, "in": 4, "not in": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// 不能用作变量名的关键字
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true,
+	"true": true, "false": true, "null": true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	vars   map[string]bool
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is 判断下一个 token 是否为给定的运算符或关键字
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokOp || t.kind == tokIdent) && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.unexpected(p.peek(), "expected '"+text+"'")
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) unexpected(t token, hint string) error {
+	what := "unexpected end of expression"
+	if t.kind != tokEOF {
+		what = "unexpected " + strconv.Quote(t.text)
+	}
+	if hint != "" {
+		what += ", " + hint
+	}
+	return &Error{Column: t.column, Msg: what}
+}
+
+// enter 增加嵌套深度，超过上限时返回错误
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &Error{Column: p.peek().column, Msg: fmt.Sprintf("expression nested deeper than %d levels", maxDepth)}
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) parse() (node, error) {
+	root, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t, "")
+	}
+	return root, nil
+}
+
+// ternary 解析 cond ? a : b，右结合
+func (p *parser) ternary() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	cond, err := p.binary(1)
+	if err != nil || !p.is("?") {
+		return cond, err
+	}
+	column := p.next().column
+	then, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, then: then, otherwise: otherwise, column: column}, nil
+}
+
+// binaryOp 返回下一个二元运算符及其占用的 token 数，不是二元运算符时返回空字符串
+func (p *parser) binaryOp() (string, int) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return "", 0
+	}
+	if t.text == "not" && p.tokens[p.pos+1].kind == tokIdent && p.tokens[p.pos+1].text == "in" {
+		return "not in", 2
+	}
+	if _, ok := precedence[t.text]; ok {
+		return t.text, 1
+	}
+	return "", 0
+}
+
+// binary 按优先级解析二元运算，左结合
+func (p *parser) binary(minPrec int) (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, width := p.binaryOp()
+		if op == "" || precedence[op] < minPrec {
+			return left, nil
+		}
+		column := p.peek().column
+		for i := 0; i < width; i++ {
+			p.next()
+		}
+		right, err := p.binary(precedence[op] + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: normalizeOp(op), left: left, right: right, column: column}
+	}
+}
+
+// normalizeOp 将关键字形式的运算符统一为符号形式
+func normalizeOp(op string) string {
+	switch op {
+	case "and":
+		return "&&"
+	case "or":
+		return "||"
+	}
+	return op
+}
+
+func (p *parser) unary() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if p.is("!") || p.is("not") || p.is("-") {
+		t := p.next()
+		op := t.text
+		if op == "not" {
+			op = "!"
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, x: x, column: t.column}, nil
+	}
+	return p.postfix()
+}
+
+// postfix 解析成员访问 a.b 和下标 a[i]
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			column := p.next().column
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.unexpected(t, "expected a field name after '.'")
+			}
+			x = &indexNode{x: x, index: &literalNode{value: t.text}, column: column}
+		case p.is("["):
+			column := p.next().column
+			index, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{x: x, index: index, column: column}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber, tokString:
+		return &literalNode{value: t.value}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if keywords[t.text] {
+			return nil, p.unexpected(t, "")
+		}
+		if p.is("(") {
+			return p.call(t)
+		}
+		if !p.vars[t.text] {
+			return nil, &Error{Column: t.column, Msg: fmt.Sprintf("unknown variable '%s'", t.text)}
+		}
+		return &varNode{name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			var elems []node
+			for !p.is("]") {
+				if len(elems) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				elem, err := p.ternary()
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, elem)
+			}
+			p.next()
+			return &listNode{elems: elems}, nil
+		}
+	}
+	return nil, p.unexpected(t, "")
+}
+
+// call 解析函数调用，函数名和参数个数在编译时检查
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, &Error{Column: name.column, Msg: fmt.Sprintf("unknown function '%s'", name.text)}
+	}
+	p.next() // (
+	var args []node
+	for !p.is(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		want := strconv.Itoa(fn.minArgs)
+		switch {
+		case fn.maxArgs < 0:
+			want = "at least " + want
+		case fn.maxArgs != fn.minArgs:
+			want = fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+		}
+		return nil, &Error{Column: name.column, Msg: fmt.Sprintf("%s() takes %s arguments, got %d", name.text, want, len(args))}
+	}
+	return &callNode{name: name.text, fn: fn, args: args, column: name.column}, nil
+}
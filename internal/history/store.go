@@ -16,14 +16,16 @@ type Record struct {
 	ItemName  string `json:"item_name"`
 	Domain    string `json:"domain"` // e.g., "music", "movie"
 	Timestamp int64  `json:"timestamp"`
+	// Experiments 产生该推荐时命中的实验及变体 (实验名 -> 变体名)，用于对比各变体的效果
+	Experiments map[string]string `json:"experiments,omitempty"`
 }
 
 // Store 定义历史记录存储接口
 type Store interface {
 	// GetRecentHistory 获取用户在指定 domain 下最近 N 天的推荐历史
 	GetRecentHistory(userID string, domain string, days int) ([]string, error)
-	// SaveHistory 保存推荐历史
-	SaveHistory(userID string, domain string, items []string) error
+	// SaveHistory 保存推荐历史，experiments 为本次推荐命中的实验及变体，可以为空
+	SaveHistory(userID string, domain string, items []string, experiments map[string]string) error
 	// Cleanup 清理超过指定天数的历史记录
 	Cleanup(days int) error
 }
@@ -116,7 +118,7 @@ func (s *FileStore) GetRecentHistory(userID string, domain string, days int) ([]
 }
 
 // SaveHistory 保存新的推荐历史到文件和内存
-func (s *FileStore) SaveHistory(userID string, domain string, items []string) error {
+func (s *FileStore) SaveHistory(userID string, domain string, items []string, experiments map[string]string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -137,10 +139,11 @@ func (s *FileStore) SaveHistory(userID string, domain string, items []string) er
 
 	for _, item := range items {
 		record := Record{
-			UserID:    userID,
-			ItemName:  item,
-			Domain:    domain,
-			Timestamp: now,
+			UserID:      userID,
+			ItemName:    item,
+			Domain:      domain,
+			Timestamp:   now,
+			Experiments: experiments,
 		}
 
 		// 1. 写入文件
@@ -6,4 +6,5 @@ type User struct {
 	Token     string   `json:"-" yaml:"token"` // Token 用于鉴权，不序列化到 JSON
 	Name      string   `json:"name" yaml:"name"`
 	Favorites []string `json:"favorites" yaml:"favorites"` // 用户的收藏列表，用于构建召回 Prompt
+	Admin     bool     `json:"-" yaml:"admin"`             // 是否可以访问 /api/v1/admin 下的管理接口
 }
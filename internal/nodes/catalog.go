@@ -0,0 +1,130 @@
+package nodes
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"recommend_engine/internal/history"
+	"recommend_engine/internal/workflow"
+	"recommend_engine/pkg/llm"
+)
+
+// Dependency 是节点需要从 Deps 中获取的共享依赖
+type Dependency string
+
+const (
+	DepLLM     Dependency = "llm"     // LLM 客户端，按 llm.yaml 中的配置名获取
+	DepHistory Dependency = "history" // 推荐历史存储
+)
+
+// LLMProvider 按 llm.yaml 中的配置名创建 LLM 客户端
+type LLMProvider interface {
+	LLMClient(key string) (llm.Client, error)
+}
+
+// Deps 是所有节点共享的依赖容器，由 main 在启动时构建一次
+// 新增依赖时在这里加字段和对应的 Dependency，并在 missing 中检查
+type Deps struct {
+	LLM     LLMProvider
+	History history.Store
+}
+
+// missing 返回 required 中未提供的依赖
+func (d *Deps) missing(required []Dependency) []Dependency {
+	var result []Dependency
+	for _, dep := range required {
+		var ok bool
+		switch dep {
+		case DepLLM:
+			ok = d != nil && d.LLM != nil
+		case DepHistory:
+			ok = d != nil && d.History != nil
+		}
+		if !ok {
+			result = append(result, dep)
+		}
+	}
+	return result
+}
+
+// Spec 描述一种节点类型，由各节点文件在 init 中通过 Register 登记
+type Spec struct {
+	Type        string       // 配置中使用的 type
+	Stage       string       // 节点的 Type()：recall / filter / rank
+	Description string       // 一句话说明
+	Config      interface{}  // 配置结构体的零值，用于生成配置说明；没有配置项时为 nil
+	Requires    []Dependency // 需要的共享依赖
+	Build       func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error)
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = make(map[string]Spec)
+)
+
+// Register 登记一种节点类型，类型名重复或缺少 Build 时 panic
+func Register(spec Spec) {
+	if spec.Type == "" || spec.Build == nil {
+		panic(fmt.Sprintf("nodes: invalid spec for type '%s'", spec.Type))
+	}
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if _, exists := catalog[spec.Type]; exists {
+		panic(fmt.Sprintf("nodes: type '%s' registered twice", spec.Type))
+	}
+	catalog[spec.Type] = spec
+}
+
+// Specs 返回所有已登记的节点类型，按类型名排序
+func Specs() []Spec {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	specs := make([]Spec, 0, len(catalog))
+	for _, spec := range catalog {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Type < specs[j].Type })
+	return specs
+}
+
+// NewRegistry 创建包含所有已登记节点类型的 Registry
+// 缺少依赖的节点类型仍然会注册，但在配置中使用时报错
+func NewRegistry(deps *Deps) *workflow.Registry {
+	registry := workflow.NewRegistry()
+	for _, spec := range Specs() {
+		spec := spec
+		registry.Register(spec.Type, func(cfg workflow.NodeConfig) (workflow.Node, error) {
+			if missing := deps.missing(spec.Requires); len(missing) > 0 {
+				return nil, fmt.Errorf("node type '%s' requires %v, which is not available", spec.Type, missing)
+			}
+			return spec.Build(cfg, deps)
+		})
+	}
+	return registry
+}
+
+// TypeInfo 是节点类型对外展示的描述
+type TypeInfo struct {
+	Type        string                 `json:"type"`
+	Stage       string                 `json:"stage"`
+	Description string                 `json:"description"`
+	Requires    []Dependency           `json:"requires,omitempty"`
+	Config      []workflow.ConfigField `json:"config,omitempty"`
+}
+
+// Catalog 返回所有节点类型及其配置项的描述，按类型名排序
+func Catalog() []TypeInfo {
+	specs := Specs()
+	result := make([]TypeInfo, len(specs))
+	for i, spec := range specs {
+		result[i] = TypeInfo{
+			Type:        spec.Type,
+			Stage:       spec.Stage,
+			Description: spec.Description,
+			Requires:    spec.Requires,
+			Config:      workflow.DescribeConfig(spec.Config),
+		}
+	}
+	return result
+}
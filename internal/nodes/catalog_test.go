@@ -0,0 +1,76 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"recommend_engine/internal/workflow"
+	"recommend_engine/pkg/llm"
+)
+
+type fakeLLM struct{}
+
+func (fakeLLM) Chat(ctx context.Context, messages []llm.Message, options ...llm.Option) (string, error) {
+	return "[]", nil
+}
+
+type fakeLLMProvider map[string]llm.Client
+
+func (p fakeLLMProvider) LLMClient(key string) (llm.Client, error) {
+	client, ok := p[key]
+	if !ok {
+		return nil, errors.New("llm config key '" + key + "' not found")
+	}
+	return client, nil
+}
+
+func TestCatalog(t *testing.T) {
+	want := []string{"exec", "filter_expr", "filter_favorites", "filter_history", "rank_mix_favorites", "rank_simple", "recall_llm", "recall_static", "remote_http", "score_expr"}
+	var got []string
+	for _, info := range Catalog() {
+		got = append(got, info.Type)
+		if info.Stage == "" || info.Description == "" {
+			t.Errorf("%s: missing stage or description", info.Type)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected catalog %v, got %v", want, got)
+	}
+
+	for _, info := range Catalog() {
+		if info.Type != "recall_llm" {
+			continue
+		}
+		if len(info.Requires) != 1 || info.Requires[0] != DepLLM {
+			t.Errorf("expected recall_llm to require llm, got %v", info.Requires)
+		}
+		if len(info.Config) != 2 || info.Config[0].Key != "llm_config_key" || !info.Config[0].Required {
+			t.Errorf("unexpected recall_llm config: %+v", info.Config)
+		}
+	}
+}
+
+func TestNewRegistryDeps(t *testing.T) {
+	llmCfg := workflow.NodeConfig{Name: "llm", Type: "recall_llm", Config: map[string]interface{}{"llm_config_key": "doubao"}}
+
+	// 缺少依赖时在使用该类型的节点上报错，其余类型不受影响
+	registry := NewRegistry(&Deps{})
+	if _, err := registry.CreateNode(llmCfg); err == nil || !strings.Contains(err.Error(), "requires [llm]") {
+		t.Errorf("expected missing dependency error, got %v", err)
+	}
+	if _, err := registry.CreateNode(workflow.NodeConfig{Name: "fav", Type: "filter_favorites"}); err != nil {
+		t.Errorf("filter_favorites should not need any dependency: %v", err)
+	}
+
+	registry = NewRegistry(&Deps{LLM: fakeLLMProvider{"doubao": fakeLLM{}}})
+	if _, err := registry.CreateNode(llmCfg); err != nil {
+		t.Errorf("expected recall_llm to build, got %v", err)
+	}
+	llmCfg.Config = map[string]interface{}{"llm_config_key": "gpt"}
+	var cfgErr *workflow.ConfigError
+	if _, err := registry.CreateNode(llmCfg); !errors.As(err, &cfgErr) || cfgErr.Path != "config.llm_config_key" {
+		t.Errorf("expected config error on llm_config_key, got %v", err)
+	}
+}
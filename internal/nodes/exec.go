@@ -0,0 +1,525 @@
+package nodes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"recommend_engine/internal/logger"
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+const (
+	execModePerCall = "per_call" // 每次执行启动一个进程，读到 EOF 为止
+	execModeWorker  = "worker"   // 常驻进程，每行一个请求、每行一个响应
+
+	// execStderrLimit 单次调用保留的 stderr 字节数，用于错误信息
+	execStderrLimit = 4096
+)
+
+// errExecOutputLimit 表示进程的输出超过了 max_output_bytes
+var errExecOutputLimit = errors.New("exec output limit exceeded")
+
+// ExecNode 调用外部进程处理候选集，进程通过 stdin / stdout 交换 JSON
+// 协议见 docs/development.md 中的 "外部进程节点"
+// 配置了 max_memory_mb 等资源限制时，通过 prlimit (util-linux) 启动进程，限制对其派生的子进程同样生效；
+// 文件系统、网络隔离不在节点的职责范围内
+type ExecNode struct {
+	name      string
+	stage     string
+	mode      string
+	command   []string
+	dir       string
+	env       []string
+	params    map[string]interface{}
+	timeout   time.Duration
+	maxOutput int
+
+	// worker 模式下的进程池，容量为 workers；取出 nil 表示该位置需要 (重新) 启动进程
+	pool chan *execWorker
+}
+
+// ExecConfig exec 节点的配置
+type ExecConfig struct {
+	Command        []string               `config:"command" required:"true" min:"1"`                // 可执行文件及参数
+	Stage          string                 `config:"stage" default:"rank" enum:"recall,filter,rank"` // 节点所属阶段，决定如何使用返回的候选集
+	Mode           string                 `config:"mode" default:"per_call" enum:"per_call,worker"`
+	Workers        int                    `config:"workers" default:"1" min:"1" max:"64"` // worker 模式下的进程数
+	CallTimeoutMs  int                    `config:"call_timeout_ms" default:"2000" min:"1"`
+	MaxOutputBytes int                    `config:"max_output_bytes" default:"1048576" min:"1"` // 单次响应的最大字节数
+	Dir            string                 `config:"dir"`                                        // 工作目录，默认为引擎的工作目录
+	InheritEnv     []string               `config:"inherit_env"`                                // 从引擎继承的环境变量名，PATH 总是继承
+	Env            map[string]string      `config:"env"`                                        // 追加的环境变量
+	MaxMemoryMB    int                    `config:"max_memory_mb" min:"0"`                      // 进程的虚拟内存上限 (RLIMIT_AS)，0 表示不限制
+	MaxCPUSeconds  int                    `config:"max_cpu_seconds" min:"0"`                    // 进程累计的 CPU 时间上限 (RLIMIT_CPU)，0 表示不限制
+	MaxOpenFiles   int                    `config:"max_open_files" min:"0"`                     // 进程可打开的文件描述符数 (RLIMIT_NOFILE)，0 表示不限制
+	Params         map[string]interface{} `config:"params"`                                     // 原样传给进程的参数
+}
+
+func init() {
+	Register(Spec{
+		Type:        "exec",
+		Stage:       "any",
+		Description: "调用外部进程，通过 stdin / stdout 交换 JSON 召回、过滤或重排候选集",
+		Config:      ExecConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewExecNode(cfg)
+		},
+	})
+}
+
+func NewExecNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c ExecConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Command[0] == "" {
+		return nil, &workflow.ConfigError{Path: "config.command[0]", Err: fmt.Errorf("must be a non-empty string")}
+	}
+
+	command := c.Command
+	if limits := execLimits(c); len(limits) > 0 {
+		prlimit, err := exec.LookPath("prlimit")
+		if err != nil {
+			return nil, &workflow.ConfigError{Path: "config", Err: fmt.Errorf("resource limits require the prlimit command (util-linux): %w", err)}
+		}
+		command = append(append([]string{prlimit}, limits...), append([]string{"--"}, c.Command...)...)
+	}
+
+	n := &ExecNode{
+		name:      cfg.Name,
+		stage:     c.Stage,
+		mode:      c.Mode,
+		command:   command,
+		dir:       c.Dir,
+		env:       execEnv(c.InheritEnv, c.Env),
+		params:    c.Params,
+		timeout:   time.Duration(c.CallTimeoutMs) * time.Millisecond,
+		maxOutput: c.MaxOutputBytes,
+	}
+	if c.Mode == execModeWorker {
+		n.pool = make(chan *execWorker, c.Workers)
+		for i := 0; i < c.Workers; i++ {
+			n.pool <- nil
+		}
+	}
+	return n, nil
+}
+
+// execLimits 返回配置的资源限制对应的 prlimit 参数，软、硬限制相同，没有配置时返回 nil
+func execLimits(c ExecConfig) []string {
+	var args []string
+	if c.MaxMemoryMB > 0 {
+		args = append(args, fmt.Sprintf("--as=%d", int64(c.MaxMemoryMB)<<20))
+	}
+	if c.MaxCPUSeconds > 0 {
+		args = append(args, fmt.Sprintf("--cpu=%d", c.MaxCPUSeconds))
+	}
+	if c.MaxOpenFiles > 0 {
+		args = append(args, fmt.Sprintf("--nofile=%d", c.MaxOpenFiles))
+	}
+	return args
+}
+
+// execEnv 构造子进程的环境变量
+// 只继承 PATH 和 inherit 中列出的变量，引擎的其他环境变量 (数据库密码、API Key 等) 不会传给子进程；
+// extra 中的变量追加在最后，可以覆盖继承的值
+func execEnv(inherit []string, extra map[string]string) []string {
+	env := []string{}
+	for _, key := range append([]string{"PATH"}, inherit...) {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+extra[k])
+	}
+	return env
+}
+
+func (n *ExecNode) Name() string { return n.name }
+func (n *ExecNode) Type() string { return n.stage }
+
+// execResponse 是进程返回的响应
+type execResponse struct {
+	Candidates *[]*model.Item    `json:"candidates"`
+	Reasons    map[string]string `json:"reasons,omitempty"` // filter：被移除条目的 id -> 原因
+	Logs       []string          `json:"logs,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func (n *ExecNode) Execute(ctx *workflow.Context) error {
+	candidates := ctx.GetCandidates()
+	payload, err := json.Marshal(newExternalRequest(ctx, n.name, n.stage, n.params, candidates))
+	if err != nil {
+		return fmt.Errorf("failed to encode exec request: %w", err)
+	}
+
+	var out []byte
+	if n.mode == execModeWorker {
+		out, err = n.callWorker(ctx.Ctx, payload)
+	} else {
+		out, err = n.runOnce(ctx.Ctx, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	var resp execResponse
+	if err := json.Unmarshal(bytes.TrimSpace(out), &resp); err != nil {
+		return fmt.Errorf("invalid exec response: %w", err)
+	}
+	for _, msg := range resp.Logs {
+		ctx.AddLog(fmt.Sprintf("[%s] %s", n.name, msg))
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("exec process reported: %s", resp.Error)
+	}
+	if resp.Candidates == nil {
+		return fmt.Errorf("invalid exec response: missing candidates")
+	}
+	items := *resp.Candidates
+	for i, item := range items {
+		if item == nil || item.ID == "" {
+			return fmt.Errorf("invalid exec response: candidates[%d] has no id", i)
+		}
+	}
+	return n.apply(ctx, candidates, items, resp.Reasons)
+}
+
+// apply 按节点阶段使用返回的候选集
+//   - recall：作为本节点的召回结果合并到候选集
+//   - filter：只能移除条目，保留原有条目及顺序，忽略对条目的修改
+//   - rank：整体替换候选集 (可以重排、改分、截断)
+func (n *ExecNode) apply(ctx *workflow.Context, before, items []*model.Item, reasons map[string]string) error {
+	if n.stage == "recall" {
+		for _, item := range items {
+			if item.Source == "" {
+				item.Source = n.name
+			}
+		}
+		ctx.SetRecallResult(n.name, items)
+		ctx.AddLog(fmt.Sprintf("Exec recall (%s) returned %d items", n.name, len(items)))
+		return nil
+	}
+
+	returned := make(map[string]bool, len(items))
+	for _, item := range items {
+		returned[item.ID] = true
+	}
+	if n.stage == "filter" {
+		existing := make(map[string]bool, len(before))
+		for _, item := range before {
+			existing[item.ID] = true
+		}
+		for _, item := range items {
+			if !existing[item.ID] {
+				return fmt.Errorf("exec filter returned unknown item '%s'", item.ID)
+			}
+		}
+	}
+
+	var kept []*model.Item
+	for _, item := range before {
+		if returned[item.ID] {
+			kept = append(kept, item)
+			continue
+		}
+		reason := reasons[item.ID]
+		if reason == "" {
+			reason = fmt.Sprintf("dropped by exec node '%s'", n.name)
+		}
+		ctx.MarkRemoved(item, reason)
+	}
+	if n.stage == "rank" {
+		kept = items
+	}
+	ctx.UpdateCandidates(kept)
+	ctx.AddLog(fmt.Sprintf("Exec %s (%s) kept %d of %d items", n.stage, n.name, len(kept), len(before)))
+	return nil
+}
+
+// newCmd 创建子进程，子进程及其派生的进程位于独立的进程组，超时时一起结束
+func (n *ExecNode) newCmd() *exec.Cmd {
+	cmd := exec.Command(n.command[0], n.command[1:]...)
+	cmd.Dir = n.dir
+	cmd.Env = n.env
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// runOnce 为本次执行启动一个进程，写入请求后关闭 stdin，读取 stdout 直到进程退出
+// 超时或输出超限时结束整个进程组并立即返回，不等待仍持有 stdout 的进程
+func (n *ExecNode) runOnce(ctx context.Context, payload []byte) ([]byte, error) {
+	callCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	cmd := n.newCmd()
+	cmd.Stdin = bytes.NewReader(payload)
+	stdout := &limitedBuffer{limit: n.maxOutput, exceeded: make(chan struct{})}
+	cmd.Stdout = stdout
+	stderr := &tailBuffer{limit: execStderrLimit}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", n.command[0], err)
+	}
+
+	// Wait 在进程退出且所有持有 stdout / stderr 的进程关闭管道后返回
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+
+	var waitErr error
+	select {
+	case waitErr = <-waitDone:
+	case <-stdout.exceeded:
+		killProcessGroup(cmd)
+		return nil, fmt.Errorf("exec output exceeds %d bytes", n.maxOutput)
+	case <-callCtx.Done():
+		killProcessGroup(cmd)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("exec process timed out after %v: %w", n.timeout, context.DeadlineExceeded)
+	}
+
+	if stdout.overflow {
+		return nil, fmt.Errorf("exec output exceeds %d bytes", n.maxOutput)
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("exec process failed: %w%s", waitErr, stderr.suffix())
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// Init 在 worker 模式下启动所有常驻进程，启动失败时配置加载失败
+func (n *ExecNode) Init(ctx context.Context) error {
+	if n.pool == nil {
+		return nil
+	}
+	var started []*execWorker
+	for i := 0; i < cap(n.pool); i++ {
+		<-n.pool
+		w, err := n.startWorker()
+		if err != nil {
+			for _, w := range started {
+				w.kill()
+			}
+			for j := 0; j <= i; j++ {
+				n.pool <- nil
+			}
+			return err
+		}
+		started = append(started, w)
+	}
+	for _, w := range started {
+		n.pool <- w
+	}
+	return nil
+}
+
+// Close 结束所有常驻进程
+// 引擎保证调用时已经没有使用该节点的请求，进程池中的所有位置都已归还
+func (n *ExecNode) Close() error {
+	if n.pool == nil {
+		return nil
+	}
+	for i := 0; i < cap(n.pool); i++ {
+		select {
+		case w := <-n.pool:
+			if w != nil {
+				w.kill()
+			}
+			n.pool <- nil
+		default:
+		}
+	}
+	return nil
+}
+
+// callWorker 从进程池取出一个常驻进程处理请求
+// 超时、输出超限或协议错误时结束该进程，下次使用该位置时重新启动
+func (n *ExecNode) callWorker(ctx context.Context, payload []byte) ([]byte, error) {
+	var w *execWorker
+	select {
+	case w = <-n.pool:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if w == nil {
+		var err error
+		if w, err = n.startWorker(); err != nil {
+			n.pool <- nil
+			return nil, err
+		}
+	}
+
+	out, err := w.call(ctx, payload, n.timeout, n.maxOutput)
+	if err != nil {
+		w.kill()
+		n.pool <- nil
+		return nil, err
+	}
+	n.pool <- w
+	return out, nil
+}
+
+// execWorker 是一个常驻进程
+type execWorker struct {
+	node       string
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdoutPipe io.ReadCloser
+	stderrPipe io.ReadCloser
+	stdout     *bufio.Reader
+	exited     chan struct{} // 进程退出后关闭
+}
+
+func (n *ExecNode) startWorker() (*execWorker, error) {
+	cmd := n.newCmd()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", n.command[0], err)
+	}
+
+	w := &execWorker{node: n.name, cmd: cmd, stdin: stdin, stdoutPipe: stdout, stderrPipe: stderr, stdout: bufio.NewReader(stdout), exited: make(chan struct{})}
+	go func() {
+		// 常驻进程的 stderr 按行写入日志
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Info("[exec %s] %s", n.name, scanner.Text())
+		}
+		cmd.Wait()
+		close(w.exited)
+	}()
+	return w, nil
+}
+
+// call 写入一行请求并读取一行响应
+func (w *execWorker) call(ctx context.Context, payload []byte, timeout time.Duration, maxOutput int) ([]byte, error) {
+	type result struct {
+		line []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if _, err := w.stdin.Write(append(payload, '\n')); err != nil {
+			done <- result{err: fmt.Errorf("failed to write to exec worker: %w", err)}
+			return
+		}
+		line, err := readLine(w.stdout, maxOutput)
+		done <- result{line: line, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-w.exited:
+		return nil, fmt.Errorf("exec worker exited: %v", w.cmd.ProcessState)
+	case <-timer.C:
+		return nil, fmt.Errorf("exec worker timed out after %v: %w", timeout, context.DeadlineExceeded)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// kill 结束进程组并关闭管道，正在进行的读写随之返回
+// 即使进程派生的子进程仍持有 stdout / stderr，读取 stderr 的协程也能结束并回收进程
+func (w *execWorker) kill() {
+	killProcessGroup(w.cmd)
+	w.stdin.Close()
+	w.stdoutPipe.Close()
+	w.stderrPipe.Close()
+}
+
+// readLine 读取一行，超过 max 字节时返回错误
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > max+1 || (len(line) > max && !bytes.HasSuffix(line, []byte("\n"))) {
+			return nil, fmt.Errorf("exec output exceeds %d bytes", max)
+		}
+		switch {
+		case err == nil:
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			return nil, fmt.Errorf("exec worker closed stdout")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// limitedBuffer 保存进程的输出，超过 limit 字节时丢弃后续输出并关闭 exceeded
+type limitedBuffer struct {
+	limit    int
+	buf      bytes.Buffer
+	overflow bool
+	exceeded chan struct{}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return 0, errExecOutputLimit
+	}
+	if b.buf.Len()+len(p) > b.limit {
+		b.overflow = true
+		close(b.exceeded)
+		return 0, errExecOutputLimit
+	}
+	return b.buf.Write(p)
+}
+
+// tailBuffer 只保留最后 limit 个字节
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+// suffix 返回附加到错误信息后的 stderr 内容
+func (b *tailBuffer) suffix() string {
+	s := strings.TrimSpace(string(b.buf))
+	if s == "" {
+		return ""
+	}
+	return ": " + s
+}
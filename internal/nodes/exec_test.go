@@ -0,0 +1,248 @@
+package nodes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// TestExecHelperProcess 不是真正的测试，而是被 exec 节点启动的外部进程
+// 行为由环境变量 EXEC_HELPER 决定
+func TestExecHelperProcess(t *testing.T) {
+	mode := os.Getenv("EXEC_HELPER")
+	if mode == "" {
+		return
+	}
+	defer os.Exit(0)
+	if mode == "hold" {
+		// 被 fork 模式启动的子进程，持有继承的 stdout 一段时间
+		time.Sleep(3 * time.Second)
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return
+		}
+		var req externalRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			fmt.Fprintln(os.Stderr, "bad request:", err)
+			os.Exit(2)
+		}
+
+		resp := map[string]interface{}{}
+		switch mode {
+		case "reverse":
+			// 倒序并按位置打分，同时报告收到的参数
+			var items []*model.Item
+			for i := len(req.Candidates) - 1; i >= 0; i-- {
+				item := req.Candidates[i]
+				item.Score = float64(i)
+				items = append(items, item)
+			}
+			resp["candidates"] = items
+			resp["logs"] = []string{fmt.Sprintf("weight=%v user=%s favorites=%d", req.Params["weight"], req.UserID, len(req.Favorites))}
+		case "drop_first":
+			resp["candidates"] = req.Candidates[1:]
+			resp["reasons"] = map[string]string{req.Candidates[0].ID: "too old"}
+		case "recall":
+			resp["candidates"] = []*model.Item{{ID: "x", Name: "x"}, {ID: "y", Name: "y", Source: "custom"}}
+		case "pid":
+			resp["candidates"] = []*model.Item{{ID: fmt.Sprint(os.Getpid())}}
+		case "sleep":
+			time.Sleep(time.Second)
+		case "fork":
+			// 派生一个继承 stdout 的子进程后直接退出，不写入响应
+			child := exec.Command(os.Args[0], "-test.run=TestExecHelperProcess")
+			child.Env = []string{"EXEC_HELPER=hold", "GORACE=atexit_sleep_ms=0"}
+			child.Stdout = os.Stdout
+			child.Start()
+			os.Exit(0)
+		case "limits":
+			// 报告进程的文件描述符上限
+			data, _ := os.ReadFile("/proc/self/limits")
+			var line string
+			for _, l := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(l, "Max open files") {
+					line = strings.Join(strings.Fields(l), " ")
+				}
+			}
+			resp["candidates"] = []*model.Item{}
+			resp["logs"] = []string{line}
+		case "env":
+			resp["candidates"] = []*model.Item{}
+			resp["logs"] = []string{fmt.Sprintf("secret=%q shared=%q", os.Getenv("RE_EXEC_SECRET"), os.Getenv("RE_EXEC_SHARED"))}
+		case "flood":
+			resp["candidates"] = []*model.Item{{ID: strings.Repeat("a", 4096)}}
+		case "fail":
+			fmt.Fprintln(os.Stderr, "model file missing")
+			os.Exit(3)
+		case "error":
+			resp["error"] = "feature store unavailable"
+		}
+		out, _ := json.Marshal(resp)
+		os.Stdout.Write(append(out, '\n'))
+	}
+}
+
+func newExecNode(t *testing.T, helper string, config map[string]interface{}) *ExecNode {
+	t.Helper()
+	cfg := map[string]interface{}{
+		"command": []interface{}{os.Args[0], "-test.run=TestExecHelperProcess"},
+		// race 模式下编译的辅助进程退出前默认等待 1 秒
+		"env": map[string]interface{}{"EXEC_HELPER": helper, "GORACE": "atexit_sleep_ms=0"},
+	}
+	for k, v := range config {
+		cfg[k] = v
+	}
+	node, err := NewExecNode(workflow.NodeConfig{Name: "ext", Type: "exec", Config: cfg})
+	if err != nil {
+		t.Fatalf("NewExecNode failed: %v", err)
+	}
+	return node.(*ExecNode)
+}
+
+func newExecContext(ids ...string) *workflow.Context {
+	ctx := workflow.NewContext(context.Background(), "u1", &model.User{ID: "u1", Favorites: []string{"f1", "f2"}})
+	var items []*model.Item
+	for _, id := range ids {
+		items = append(items, &model.Item{ID: id, Name: id})
+	}
+	ctx.UpdateCandidates(items)
+	return ctx
+}
+
+func candidateIDs(ctx *workflow.Context) string {
+	var ids []string
+	for _, item := range ctx.GetCandidates() {
+		ids = append(ids, item.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestExecNodeStages(t *testing.T) {
+	// rank：整体替换候选集，请求中包含用户、收藏和 params
+	node := newExecNode(t, "reverse", map[string]interface{}{"params": map[string]interface{}{"weight": 1.5}})
+	ctx := newExecContext("a", "b", "c")
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("rank failed: %v", err)
+	}
+	if got := candidateIDs(ctx); got != "c,b,a" {
+		t.Errorf("expected c,b,a, got %s", got)
+	}
+	if log := strings.Join(ctx.TraceLog, "\n"); !strings.Contains(log, "weight=1.5 user=u1 favorites=2") {
+		t.Errorf("expected request fields in process log, got %q", log)
+	}
+
+	// filter：只移除条目，并记录原因
+	node = newExecNode(t, "drop_first", map[string]interface{}{"stage": "filter"})
+	ctx = newExecContext("a", "b")
+	ctx.Debug = true
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("filter failed: %v", err)
+	}
+	if got := candidateIDs(ctx); got != "b" {
+		t.Errorf("expected b, got %s", got)
+	}
+
+	// recall：合并到候选集，未设置来源的条目使用节点名
+	node = newExecNode(t, "recall", map[string]interface{}{"stage": "recall"})
+	ctx = newExecContext()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("recall failed: %v", err)
+	}
+	items := ctx.GetRecallResults()["ext"]
+	if len(items) != 2 || items[0].Source != "ext" || items[1].Source != "custom" {
+		t.Errorf("unexpected recall result: %+v", items)
+	}
+}
+
+func TestExecNodeFailures(t *testing.T) {
+	cases := map[string]struct {
+		helper string
+		config map[string]interface{}
+		want   string
+	}{
+		"timeout":         {"sleep", map[string]interface{}{"call_timeout_ms": 50}, "timed out after 50ms"},
+		"output limit":    {"flood", map[string]interface{}{"max_output_bytes": 1024}, "exceeds 1024 bytes"},
+		"exit status":     {"fail", nil, "exit status 3: model file missing"},
+		"reported error":  {"error", nil, "exec process reported: feature store unavailable"},
+		"worker timeout":  {"sleep", map[string]interface{}{"mode": "worker", "call_timeout_ms": 50}, "worker timed out after 50ms"},
+		"worker overflow": {"flood", map[string]interface{}{"mode": "worker", "max_output_bytes": 1024}, "exceeds 1024 bytes"},
+		"forked child":    {"fork", map[string]interface{}{"call_timeout_ms": 100}, "timed out after 100ms"},
+	}
+	for name, tc := range cases {
+		node := newExecNode(t, tc.helper, tc.config)
+		if err := node.Init(context.Background()); err != nil {
+			t.Fatalf("%s: Init failed: %v", name, err)
+		}
+		start := time.Now()
+		err := node.Execute(newExecContext("a"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+		// 派生的子进程仍持有 stdout 时同样按 call_timeout_ms 返回
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: call took %v", name, elapsed)
+		}
+		node.Close()
+	}
+}
+
+func TestExecNodeEnvironment(t *testing.T) {
+	t.Setenv("RE_EXEC_SECRET", "db-password")
+	t.Setenv("RE_EXEC_SHARED", "shared")
+	node := newExecNode(t, "env", map[string]interface{}{"inherit_env": []interface{}{"RE_EXEC_SHARED"}})
+	ctx := newExecContext()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if log := strings.Join(ctx.TraceLog, "\n"); !strings.Contains(log, `secret="" shared="shared"`) {
+		t.Errorf("expected only inherited variables in the process environment, got %q", log)
+	}
+}
+
+func TestExecNodeResourceLimits(t *testing.T) {
+	if _, err := exec.LookPath("prlimit"); err != nil {
+		t.Skip("prlimit not available")
+	}
+	node := newExecNode(t, "limits", map[string]interface{}{"max_open_files": float64(64)})
+	ctx := newExecContext()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if log := strings.Join(ctx.TraceLog, "\n"); !strings.Contains(log, "Max open files 64 64 files") {
+		t.Errorf("expected the file descriptor limit to be applied, got %q", log)
+	}
+}
+
+func TestExecNodeWorkerReuse(t *testing.T) {
+	node := newExecNode(t, "pid", map[string]interface{}{"mode": "worker", "stage": "recall"})
+	if err := node.Init(context.Background()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer node.Close()
+
+	var pids []string
+	for i := 0; i < 3; i++ {
+		ctx := newExecContext()
+		if err := node.Execute(ctx); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		pids = append(pids, candidateIDs(ctx))
+	}
+	if pids[0] != pids[1] || pids[1] != pids[2] {
+		t.Errorf("expected a single long-lived worker, got pids %v", pids)
+	}
+}
@@ -0,0 +1,12 @@
+package nodes
+
+import "os/exec"
+
+// Windows 上没有进程组，只结束子进程本身
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,47 @@
+package nodes
+
+import (
+	"recommend_engine/internal/expr"
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// exprVars 是 filter_expr、score_expr 的表达式中可以使用的变量
+//   - id / name / score / source / meta_data：当前条目的字段
+//   - index：条目在候选集中的位置，从 0 开始
+//   - user：id、name、favorites
+//   - context：请求级参数 (ctx.Config)，如 context.domain
+var exprVars = []string{"id", "name", "score", "source", "meta_data", "index", "user", "context"}
+
+// compileExpr 编译节点配置中的表达式，错误路径指向对应的配置项
+func compileExpr(key, source string) (*expr.Program, error) {
+	p, err := expr.Compile(source, exprVars)
+	if err != nil {
+		return nil, &workflow.ConfigError{Path: "config." + key, Err: err}
+	}
+	return p, nil
+}
+
+// requestVars 返回与条目无关的变量，每次执行构造一次
+func requestVars(ctx *workflow.Context) map[string]interface{} {
+	user := map[string]interface{}{"id": ctx.UserID}
+	if ctx.User != nil {
+		user["name"] = ctx.User.Name
+		user["favorites"] = ctx.User.Favorites
+	}
+	return map[string]interface{}{"user": user, "context": ctx.Config}
+}
+
+// itemVars 返回对条目求值时的变量
+func itemVars(base map[string]interface{}, item *model.Item, index int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        item.ID,
+		"name":      item.Name,
+		"score":     item.Score,
+		"source":    item.Source,
+		"meta_data": item.MetaData,
+		"index":     index,
+		"user":      base["user"],
+		"context":   base["context"],
+	}
+}
@@ -0,0 +1,38 @@
+package nodes
+
+import (
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// externalRequest 是 exec、remote_http 等外部节点发给外部逻辑的请求
+type externalRequest struct {
+	Node       string                 `json:"node"`
+	Stage      string                 `json:"stage"`
+	UserID     string                 `json:"user_id"`
+	User       *model.User            `json:"user"`
+	Favorites  []string               `json:"favorites"`
+	Candidates []*model.Item          `json:"candidates"`
+	Context    map[string]interface{} `json:"context,omitempty"` // 请求级参数 (ctx.Config)
+	Params     map[string]interface{} `json:"params,omitempty"`  // 节点配置中的 params
+}
+
+// newExternalRequest 用当前候选集和用户信息构造请求，候选集为空时编码为 []
+func newExternalRequest(ctx *workflow.Context, name, stage string, params map[string]interface{}, candidates []*model.Item) externalRequest {
+	req := externalRequest{
+		Node:       name,
+		Stage:      stage,
+		UserID:     ctx.UserID,
+		User:       ctx.User,
+		Candidates: candidates,
+		Context:    ctx.Config,
+		Params:     params,
+	}
+	if ctx.User != nil {
+		req.Favorites = ctx.User.Favorites
+	}
+	if req.Candidates == nil {
+		req.Candidates = []*model.Item{}
+	}
+	return req
+}
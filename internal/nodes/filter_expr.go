@@ -0,0 +1,115 @@
+package nodes
+
+import (
+	"fmt"
+
+	"recommend_engine/internal/expr"
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// ExprFilterNode 按表达式过滤候选集，表达式在加载配置时编译
+type ExprFilterNode struct {
+	name    string
+	program *expr.Program
+	keep    bool // true 表示保留表达式成立的条目 (keep_if)，否则移除 (drop_if)
+	reason  string
+	onError string
+}
+
+// ExprFilterConfig filter_expr 节点的配置，drop_if 和 keep_if 必须且只能设置一个
+type ExprFilterConfig struct {
+	DropIf  string `config:"drop_if"`                                       // 成立时移除条目
+	KeepIf  string `config:"keep_if"`                                       // 不成立时移除条目
+	Reason  string `config:"reason"`                                        // 移除原因，默认为表达式本身
+	OnError string `config:"on_error" default:"keep" enum:"keep,drop,fail"` // 求值出错时保留、移除条目或使节点失败
+}
+
+func init() {
+	Register(Spec{
+		Type:        "filter_expr",
+		Stage:       "filter",
+		Description: "按表达式过滤条目，如 meta_data.year < 1990",
+		Config:      ExprFilterConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewExprFilterNode(cfg)
+		},
+	})
+}
+
+func NewExprFilterNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c ExprFilterConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	key, source := "drop_if", c.DropIf
+	switch {
+	case c.DropIf != "" && c.KeepIf != "":
+		return nil, &workflow.ConfigError{Path: "config", Err: fmt.Errorf("drop_if and keep_if cannot be used together")}
+	case c.KeepIf != "":
+		key, source = "keep_if", c.KeepIf
+	case c.DropIf == "":
+		return nil, &workflow.ConfigError{Path: "config", Err: fmt.Errorf("one of drop_if or keep_if is required")}
+	}
+	program, err := compileExpr(key, source)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := c.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("%s: %s", key, source)
+	}
+	return &ExprFilterNode{
+		name:    cfg.Name,
+		program: program,
+		keep:    key == "keep_if",
+		reason:  reason,
+		onError: c.OnError,
+	}, nil
+}
+
+func (n *ExprFilterNode) Name() string { return n.name }
+func (n *ExprFilterNode) Type() string { return "filter" }
+
+func (n *ExprFilterNode) Execute(ctx *workflow.Context) error {
+	candidates := ctx.GetCandidates()
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	base := requestVars(ctx)
+	var kept []*model.Item
+	var failed int
+	var firstErr error
+	for i, item := range candidates {
+		matched, err := n.program.EvalBool(itemVars(base, item, i))
+		if err != nil {
+			if n.onError == "fail" {
+				return fmt.Errorf("failed to evaluate %q on item '%s': %w", n.program, item.ID, err)
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("item '%s': %w", item.ID, err)
+			}
+			failed++
+			if n.onError == "keep" {
+				kept = append(kept, item)
+			} else {
+				ctx.MarkRemoved(item, fmt.Sprintf("failed to evaluate filter expression: %v", err))
+			}
+			continue
+		}
+		if matched == n.keep {
+			kept = append(kept, item)
+		} else {
+			ctx.MarkRemoved(item, n.reason)
+		}
+	}
+
+	ctx.UpdateCandidates(kept)
+	if failed > 0 {
+		ctx.AddLog(fmt.Sprintf("Expression filter (%s) failed on %d items, first error: %v", n.name, failed, firstErr))
+	}
+	ctx.AddLog(fmt.Sprintf("Expression filter (%s) removed %d items, kept %d", n.name, len(candidates)-len(kept), len(kept)))
+	return nil
+}
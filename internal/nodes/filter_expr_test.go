@@ -0,0 +1,78 @@
+package nodes
+
+import (
+	"strings"
+	"testing"
+
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+func newExprContext() *workflow.Context {
+	ctx := newExecContext()
+	ctx.Config = map[string]interface{}{"min_year": float64(1990)}
+	ctx.UpdateCandidates([]*model.Item{
+		{ID: "a", Score: 1, Source: "doubao_recall_1", MetaData: map[string]interface{}{"year": float64(1985)}},
+		{ID: "b", Score: 2, Source: "static", MetaData: map[string]interface{}{"year": float64(2003)}},
+		{ID: "f1", Score: 3, Source: "static"},
+	})
+	return ctx
+}
+
+func TestExprFilterNode(t *testing.T) {
+	cases := map[string]struct {
+		config map[string]interface{}
+		want   string
+	}{
+		"drop_if":          {map[string]interface{}{"drop_if": "meta_data.year < context.min_year"}, "b,f1"},
+		"keep_if":          {map[string]interface{}{"keep_if": "meta_data.year >= 1990"}, "b"},
+		"favorites":        {map[string]interface{}{"drop_if": "id in user.favorites"}, "a,b"},
+		"error keeps item": {map[string]interface{}{"drop_if": "meta_data.year - 2000 > 0"}, "a,f1"},
+		"error drops item": {map[string]interface{}{"drop_if": "meta_data.year - 2000 > 0", "on_error": "drop"}, "a"},
+	}
+	for name, tc := range cases {
+		node, err := NewExprFilterNode(workflow.NodeConfig{Name: "rules", Type: "filter_expr", Config: tc.config})
+		if err != nil {
+			t.Fatalf("%s: NewExprFilterNode failed: %v", name, err)
+		}
+		ctx := newExprContext()
+		if err := node.Execute(ctx); err != nil {
+			t.Errorf("%s: Execute failed: %v", name, err)
+			continue
+		}
+		if got := candidateIDs(ctx); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", name, tc.want, got)
+		}
+	}
+
+	node, _ := NewExprFilterNode(workflow.NodeConfig{Name: "rules", Type: "filter_expr", Config: map[string]interface{}{
+		"drop_if": "meta_data.year - 2000 > 0", "on_error": "fail",
+	}})
+	if err := node.Execute(newExprContext()); err == nil || !strings.Contains(err.Error(), "item 'f1'") {
+		t.Errorf("expected evaluation error on f1, got %v", err)
+	}
+}
+
+func TestExprNodeConfigErrors(t *testing.T) {
+	registry := NewRegistry(&Deps{})
+	cases := map[string]struct {
+		nodeType string
+		config   map[string]interface{}
+		want     string
+	}{
+		"syntax":       {"filter_expr", map[string]interface{}{"drop_if": "meta_data.year <"}, "config.drop_if: column 17: unexpected end of expression"},
+		"unknown var":  {"score_expr", map[string]interface{}{"expr": "scroe * 2"}, "config.expr: column 1: unknown variable 'scroe'"},
+		"bad when":     {"score_expr", map[string]interface{}{"expr": "score", "when": "source = 'x'"}, "config.when: column 8: unexpected character '='"},
+		"both":         {"filter_expr", map[string]interface{}{"drop_if": "true", "keep_if": "true"}, "cannot be used together"},
+		"neither":      {"filter_expr", map[string]interface{}{}, "one of drop_if or keep_if is required"},
+		"unknown fn":   {"filter_expr", map[string]interface{}{"keep_if": "system('ls')"}, "unknown function 'system'"},
+		"missing expr": {"score_expr", map[string]interface{}{}, "config.expr: required field is missing"},
+		"bad on_error": {"score_expr", map[string]interface{}{"expr": "1", "on_error": "drop"}, "config.on_error: must be one of [keep, fail]"},
+	}
+	for name, tc := range cases {
+		_, err := registry.CreateNode(workflow.NodeConfig{Name: "rules", Type: tc.nodeType, Config: tc.config})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
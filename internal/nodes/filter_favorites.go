@@ -11,7 +11,22 @@ type FavoritesFilterNode struct {
 	name string
 }
 
+func init() {
+	Register(Spec{
+		Type:        "filter_favorites",
+		Stage:       "filter",
+		Description: "过滤掉用户已经收藏的条目",
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewFavoritesFilterNode(cfg)
+		},
+	})
+}
+
 func NewFavoritesFilterNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	// 没有可配置项，仍然解码以拒绝拼写错误的键
+	if err := cfg.Decode(&struct{}{}); err != nil {
+		return nil, err
+	}
 	return &FavoritesFilterNode{
 		name: cfg.Name,
 	}, nil
@@ -20,6 +35,13 @@ func NewFavoritesFilterNode(cfg workflow.NodeConfig) (workflow.Node, error) {
 func (n *FavoritesFilterNode) Name() string { return n.name }
 func (n *FavoritesFilterNode) Type() string { return "filter" }
 
+func (n *FavoritesFilterNode) Access() workflow.Access {
+	return workflow.Access{
+		Reads:  []workflow.Resource{workflow.ResourceCandidates, workflow.ResourceFavorites},
+		Writes: []workflow.Resource{workflow.ResourceCandidates},
+	}
+}
+
 func (n *FavoritesFilterNode) Execute(ctx *workflow.Context) error {
 	candidates := ctx.GetCandidates()
 	if len(candidates) == 0 {
@@ -40,6 +62,7 @@ func (n *FavoritesFilterNode) Execute(ctx *workflow.Context) error {
 			kept = append(kept, item)
 		} else {
 			filteredCount++
+			ctx.MarkRemoved(item, "in user favorites")
 		}
 	}
 
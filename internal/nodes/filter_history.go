@@ -14,17 +14,35 @@ type HistoryFilterNode struct {
 	lookbackDays int
 }
 
+// HistoryFilterConfig filter_history 节点的配置
+type HistoryFilterConfig struct {
+	LookbackDays int `config:"lookback_days" default:"7" min:"1"`
+}
+
+func init() {
+	Register(Spec{
+		Type:        "filter_history",
+		Stage:       "filter",
+		Description: "过滤掉最近 lookback_days 天内推荐过的条目",
+		Config:      HistoryFilterConfig{},
+		Requires:    []Dependency{DepHistory},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewHistoryFilterNode(cfg, deps.History)
+		},
+	})
+}
+
 // NewHistoryFilterNode 工厂函数
 func NewHistoryFilterNode(cfg workflow.NodeConfig, store history.Store) (workflow.Node, error) {
-	days, ok := cfg.Config["lookback_days"].(float64)
-	if !ok {
-		days = 7 // default
+	var c HistoryFilterConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
 	}
 
 	return &HistoryFilterNode{
 		name:         cfg.Name,
 		store:        store,
-		lookbackDays: int(days),
+		lookbackDays: c.LookbackDays,
 	}, nil
 }
 
@@ -68,6 +86,7 @@ func (n *HistoryFilterNode) Execute(ctx *workflow.Context) error {
 			kept = append(kept, item)
 		} else {
 			filteredCount++
+			ctx.MarkRemoved(item, fmt.Sprintf("recommended within last %d days", n.lookbackDays))
 		}
 	}
 
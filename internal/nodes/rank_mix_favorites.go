@@ -14,21 +14,45 @@ type MixFavoritesRankNode struct {
 	mixCount int
 }
 
+// MixFavoritesRankConfig rank_mix_favorites 节点的配置
+type MixFavoritesRankConfig struct {
+	MixCount int `config:"mix_count" default:"2" min:"1"` // 默认插入 2 首
+}
+
+func init() {
+	Register(Spec{
+		Type:        "rank_mix_favorites",
+		Stage:       "rank",
+		Description: "在结果中随机插入若干首用户收藏",
+		Config:      MixFavoritesRankConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewMixFavoritesRankNode(cfg)
+		},
+	})
+}
+
 func NewMixFavoritesRankNode(cfg workflow.NodeConfig) (workflow.Node, error) {
-	count, _ := cfg.Config["mix_count"].(float64)
-	if count <= 0 {
-		count = 2 // 默认插入 2 首
+	var c MixFavoritesRankConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
 	}
 
 	return &MixFavoritesRankNode{
 		name:     cfg.Name,
-		mixCount: int(count),
+		mixCount: c.MixCount,
 	}, nil
 }
 
 func (n *MixFavoritesRankNode) Name() string { return n.name }
 func (n *MixFavoritesRankNode) Type() string { return "rank" }
 
+func (n *MixFavoritesRankNode) Access() workflow.Access {
+	return workflow.Access{
+		Reads:  []workflow.Resource{workflow.ResourceCandidates, workflow.ResourceFavorites},
+		Writes: []workflow.Resource{workflow.ResourceCandidates},
+	}
+}
+
 func (n *MixFavoritesRankNode) Execute(ctx *workflow.Context) error {
 	favorites := ctx.User.Favorites
 	if len(favorites) == 0 {
@@ -15,24 +15,50 @@ type SimpleRankNode struct {
 	order string // "desc", "asc", "shuffle"
 }
 
-func NewSimpleRankNode(cfg workflow.NodeConfig) (workflow.Node, error) {
-	limit, _ := cfg.Config["limit"].(float64)
-	order, _ := cfg.Config["order"].(string)
+// SimpleRankConfig rank_simple 节点的配置
+type SimpleRankConfig struct {
+	// 默认打乱，因为 MVP 中 LLM 返回的顺序可能就是相关性顺序，但也可能需要打散
+	Order string `config:"order" default:"shuffle" enum:"shuffle,desc,asc"`
+	Limit int    `config:"limit" min:"0"` // 0 表示不截断
+}
+
+func init() {
+	Register(Spec{
+		Type:        "rank_simple",
+		Stage:       "rank",
+		Description: "按分数排序或随机打乱，可截断到 limit 条",
+		Config:      SimpleRankConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewSimpleRankNode(cfg)
+		},
+	})
+}
 
-	if order == "" {
-		order = "shuffle" // 默认打乱，因为 MVP 中 LLM 返回的顺序可能就是相关性顺序，但也可能需要打散
+func NewSimpleRankNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c SimpleRankConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
 	}
 
 	return &SimpleRankNode{
 		name:  cfg.Name,
-		limit: int(limit),
-		order: order,
+		limit: c.Limit,
+		order: c.Order,
 	}, nil
 }
 
 func (n *SimpleRankNode) Name() string { return n.name }
 func (n *SimpleRankNode) Type() string { return "rank" }
 
+// Access 配置了 limit 时会截断候选集
+func (n *SimpleRankNode) Access() workflow.Access {
+	return workflow.Access{
+		Reads:     []workflow.Resource{workflow.ResourceCandidates},
+		Writes:    []workflow.Resource{workflow.ResourceCandidates},
+		Truncates: n.limit > 0,
+	}
+}
+
 func (n *SimpleRankNode) Execute(ctx *workflow.Context) error {
 	candidates := ctx.GetCandidates()
 	if len(candidates) == 0 {
@@ -58,6 +84,9 @@ func (n *SimpleRankNode) Execute(ctx *workflow.Context) error {
 
 	// 截断
 	if n.limit > 0 && len(candidates) > n.limit {
+		for _, item := range candidates[n.limit:] {
+			ctx.MarkRemoved(item, fmt.Sprintf("truncated by limit %d", n.limit))
+		}
 		candidates = candidates[:n.limit]
 	}
 
@@ -18,6 +18,35 @@ type LLMRecallNode struct {
 	count     int
 }
 
+// LLMRecallConfig recall_llm 节点的配置
+type LLMRecallConfig struct {
+	LLMConfigKey string `config:"llm_config_key" required:"true" min:"1"` // llm.yaml 中的模型配置名
+	Count        int    `config:"count" default:"50" min:"1"`             // 每次请求推荐的数量
+}
+
+func init() {
+	Register(Spec{
+		Type:        "recall_llm",
+		Stage:       "recall",
+		Description: "以用户收藏为种子，调用兼容 OpenAI 接口的 LLM 召回",
+		Config:      LLMRecallConfig{},
+		Requires:    []Dependency{DepLLM},
+		Build:       buildLLMRecallNode,
+	})
+}
+
+func buildLLMRecallNode(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+	var c LLMRecallConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	client, err := deps.LLM.LLMClient(c.LLMConfigKey)
+	if err != nil {
+		return nil, &workflow.ConfigError{Path: "config.llm_config_key", Err: err}
+	}
+	return NewLLMRecallNode(cfg.Name, client, c.Count), nil
+}
+
 // NewLLMRecallNode 创建一个新的 LLMRecallNode
 // 注意：现在 client 由外部注入，不再负责从 config 创建
 func NewLLMRecallNode(name string, client llm.Client, count int) *LLMRecallNode {
@@ -31,6 +60,14 @@ func NewLLMRecallNode(name string, client llm.Client, count int) *LLMRecallNode
 func (n *LLMRecallNode) Name() string { return n.name }
 func (n *LLMRecallNode) Type() string { return "recall" }
 
+// Access 以用户收藏作为 Prompt 的种子，写入候选集和召回结果
+func (n *LLMRecallNode) Access() workflow.Access {
+	return workflow.Access{
+		Reads:  []workflow.Resource{workflow.ResourceFavorites},
+		Writes: []workflow.Resource{workflow.ResourceCandidates, workflow.ResourceRecallResults},
+	}
+}
+
 func (n *LLMRecallNode) Execute(ctx *workflow.Context) error {
 	favorites := ctx.User.Favorites
 	if len(favorites) == 0 {
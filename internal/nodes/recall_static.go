@@ -0,0 +1,70 @@
+package nodes
+
+import (
+	"fmt"
+
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// StaticRecallNode 从配置中的固定列表召回 (如热门歌曲)
+// 通常作为 fallback 节点的最后一级兜底
+type StaticRecallNode struct {
+	name  string
+	items []string
+}
+
+// StaticRecallConfig recall_static 节点的配置
+type StaticRecallConfig struct {
+	Items []string `config:"items" required:"true" min:"1"`
+}
+
+func init() {
+	Register(Spec{
+		Type:        "recall_static",
+		Stage:       "recall",
+		Description: "从配置中的固定列表召回，通常作为兜底",
+		Config:      StaticRecallConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewStaticRecallNode(cfg)
+		},
+	})
+}
+
+func NewStaticRecallNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c StaticRecallConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	for i, name := range c.Items {
+		if name == "" {
+			return nil, &workflow.ConfigError{
+				Path: fmt.Sprintf("config.items[%d]", i),
+				Err:  fmt.Errorf("must be a non-empty string"),
+			}
+		}
+	}
+
+	return &StaticRecallNode{
+		name:  cfg.Name,
+		items: c.Items,
+	}, nil
+}
+
+func (n *StaticRecallNode) Name() string { return n.name }
+func (n *StaticRecallNode) Type() string { return "recall" }
+
+func (n *StaticRecallNode) Execute(ctx *workflow.Context) error {
+	items := make([]*model.Item, 0, len(n.items))
+	for _, name := range n.items {
+		items = append(items, &model.Item{
+			ID:     name,
+			Name:   name,
+			Source: n.name,
+		})
+	}
+
+	ctx.SetRecallResult(n.name, items)
+	ctx.AddLog(fmt.Sprintf("Static Recall (%s) returned %d items", n.name, len(items)))
+	return nil
+}
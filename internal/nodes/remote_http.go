@@ -0,0 +1,329 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// RemoteHTTPNode 把候选集和用户信息 POST 给内部的召回、打分服务，按响应增删条目或重新打分
+// 协议见 docs/development.md 中的 "远程服务节点"
+type RemoteHTTPNode struct {
+	name        string
+	stage       string
+	url         string
+	headers     map[string]string
+	params      map[string]interface{}
+	timeout     time.Duration
+	retries     int
+	backoff     time.Duration
+	maxResponse int
+	sortByScore bool
+	client      *http.Client
+}
+
+// RemoteHTTPConfig remote_http 节点的配置
+type RemoteHTTPConfig struct {
+	URL              string                 `config:"url" required:"true" min:"1"`
+	Stage            string                 `config:"stage" default:"rank" enum:"recall,filter,rank"` // 节点所属阶段，决定接受响应中的哪些字段
+	Headers          map[string]string      `config:"headers"`                                        // 每次请求附带的 Header，如鉴权 Token
+	CallTimeoutMs    int                    `config:"call_timeout_ms" default:"1000" min:"1"`         // 单次请求的超时，重试时分别计算
+	Retries          int                    `config:"retries" default:"0" min:"0" max:"5"`            // 网络错误、429 和 5xx 时的重试次数
+	RetryBackoffMs   int                    `config:"retry_backoff_ms" default:"100" min:"0"`         // 第 n 次重试前等待 n 倍的时间
+	MaxResponseBytes int                    `config:"max_response_bytes" default:"1048576" min:"1"`
+	SortByScore      bool                   `config:"sort_by_score"` // 应用 scores 后按分数降序排列
+	Params           map[string]interface{} `config:"params"`        // 原样放在请求的 params 中
+}
+
+func init() {
+	Register(Spec{
+		Type:        "remote_http",
+		Stage:       "any",
+		Description: "调用内部 HTTP 服务召回、过滤或重新打分",
+		Config:      RemoteHTTPConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewRemoteHTTPNode(cfg)
+		},
+	})
+}
+
+func NewRemoteHTTPNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c RemoteHTTPConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &workflow.ConfigError{Path: "config.url", Err: fmt.Errorf("must be an absolute http(s) URL, got %q", c.URL)}
+	}
+
+	return &RemoteHTTPNode{
+		name:        cfg.Name,
+		stage:       c.Stage,
+		url:         c.URL,
+		headers:     c.Headers,
+		params:      c.Params,
+		timeout:     time.Duration(c.CallTimeoutMs) * time.Millisecond,
+		retries:     c.Retries,
+		backoff:     time.Duration(c.RetryBackoffMs) * time.Millisecond,
+		maxResponse: c.MaxResponseBytes,
+		sortByScore: c.SortByScore,
+		client:      &http.Client{},
+	}, nil
+}
+
+func (n *RemoteHTTPNode) Name() string { return n.name }
+func (n *RemoteHTTPNode) Type() string { return n.stage }
+
+// remoteResponse 是远程服务返回的响应，所有字段都是可选的
+type remoteResponse struct {
+	Candidates *[]*model.Item     `json:"candidates"` // rank：整体替换候选集，不能与 add / remove 同时使用
+	Add        []*model.Item      `json:"add"`        // recall / rank：新增的条目
+	Remove     []remoteRemoval    `json:"remove"`     // filter / rank：移除的条目
+	Scores     map[string]float64 `json:"scores"`     // rank：条目 id -> 新的分数
+	Logs       []string           `json:"logs"`
+	Error      string             `json:"error"`
+}
+
+type remoteRemoval struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func (n *RemoteHTTPNode) Execute(ctx *workflow.Context) error {
+	candidates := ctx.GetCandidates()
+	payload, err := json.Marshal(newExternalRequest(ctx, n.name, n.stage, n.params, candidates))
+	if err != nil {
+		return fmt.Errorf("failed to encode remote request: %w", err)
+	}
+
+	body, err := n.post(ctx.Ctx, payload)
+	if err != nil {
+		return err
+	}
+	var resp remoteResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid remote response: %w", err)
+	}
+	for _, msg := range resp.Logs {
+		ctx.AddLog(fmt.Sprintf("[%s] %s", n.name, msg))
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("remote service reported: %s", resp.Error)
+	}
+	if err := n.checkResponse(&resp); err != nil {
+		return fmt.Errorf("invalid remote response: %w", err)
+	}
+	n.apply(ctx, candidates, &resp)
+	return nil
+}
+
+// post 发送请求，网络错误、429 和 5xx 时按配置重试
+func (n *RemoteHTTPNode) post(ctx context.Context, payload []byte) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(time.Duration(attempt) * n.backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+		body, retryable, err := n.postOnce(ctx, payload)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+	if n.retries > 0 {
+		return nil, fmt.Errorf("remote call failed after %d attempts: %w", n.retries+1, lastErr)
+	}
+	return nil, lastErr
+}
+
+// postOnce 发送一次请求，返回响应体以及失败时是否值得重试
+func (n *RemoteHTTPNode) postOnce(ctx context.Context, payload []byte) ([]byte, bool, error) {
+	callCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(callCtx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		if callCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, true, fmt.Errorf("remote call timed out after %v: %w", n.timeout, context.DeadlineExceeded)
+		}
+		return nil, true, fmt.Errorf("remote call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(n.maxResponse)+1))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read remote response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retryable, &remoteStatusError{status: resp.StatusCode, body: truncate(string(body), 200)}
+	}
+	if len(body) > n.maxResponse {
+		return nil, false, fmt.Errorf("remote response exceeds %d bytes", n.maxResponse)
+	}
+	return body, false, nil
+}
+
+// remoteStatusError 表示远程服务返回了非 200 的状态码，实现 workflow.HTTPStatusError
+type remoteStatusError struct {
+	status int
+	body   string
+}
+
+func (e *remoteStatusError) Error() string {
+	return fmt.Sprintf("remote service returned status %d: %s", e.status, e.body)
+}
+
+func (e *remoteStatusError) HTTPStatus() int { return e.status }
+
+// checkResponse 检查响应中的字段是否适用于节点所在阶段
+func (n *RemoteHTTPNode) checkResponse(resp *remoteResponse) error {
+	allowed := map[string]bool{}
+	switch n.stage {
+	case "recall":
+		allowed["add"] = true
+	case "filter":
+		allowed["remove"] = true
+	case "rank":
+		allowed["candidates"], allowed["add"], allowed["remove"], allowed["scores"] = true, true, true, true
+	}
+	present := map[string]bool{
+		"candidates": resp.Candidates != nil,
+		"add":        len(resp.Add) > 0,
+		"remove":     len(resp.Remove) > 0,
+		"scores":     len(resp.Scores) > 0,
+	}
+	for _, field := range []string{"candidates", "add", "remove", "scores"} {
+		if present[field] && !allowed[field] {
+			return fmt.Errorf("'%s' is not supported by a %s node", field, n.stage)
+		}
+	}
+	if present["candidates"] && (present["add"] || present["remove"]) {
+		return fmt.Errorf("'candidates' cannot be combined with 'add' or 'remove'")
+	}
+
+	var items []*model.Item
+	if resp.Candidates != nil {
+		items = *resp.Candidates
+	}
+	for i, item := range append(items, resp.Add...) {
+		if item == nil || item.ID == "" {
+			return fmt.Errorf("item %d has no id", i)
+		}
+	}
+	for i, r := range resp.Remove {
+		if r.ID == "" {
+			return fmt.Errorf("remove[%d] has no id", i)
+		}
+	}
+	return nil
+}
+
+// apply 依次应用 candidates、remove、add、scores
+func (n *RemoteHTTPNode) apply(ctx *workflow.Context, before []*model.Item, resp *remoteResponse) {
+	for _, item := range resp.Add {
+		if item.Source == "" {
+			item.Source = n.name
+		}
+	}
+	if n.stage == "recall" {
+		ctx.SetRecallResult(n.name, resp.Add)
+		ctx.AddLog(fmt.Sprintf("Remote recall (%s) returned %d items", n.name, len(resp.Add)))
+		return
+	}
+
+	items := before
+	if resp.Candidates != nil {
+		items = *resp.Candidates
+		returned := make(map[string]bool, len(items))
+		for _, item := range items {
+			returned[item.ID] = true
+		}
+		for _, item := range before {
+			if !returned[item.ID] {
+				ctx.MarkRemoved(item, fmt.Sprintf("dropped by remote node '%s'", n.name))
+			}
+		}
+	}
+
+	if len(resp.Remove) > 0 {
+		reasons := make(map[string]string, len(resp.Remove))
+		for _, r := range resp.Remove {
+			reasons[r.ID] = r.Reason
+			if r.Reason == "" {
+				reasons[r.ID] = fmt.Sprintf("removed by remote node '%s'", n.name)
+			}
+		}
+		var kept []*model.Item
+		for _, item := range items {
+			if reason, ok := reasons[item.ID]; ok {
+				ctx.MarkRemoved(item, reason)
+				continue
+			}
+			kept = append(kept, item)
+		}
+		items = kept
+	}
+
+	items = append(items, resp.Add...)
+
+	if len(resp.Scores) > 0 {
+		// 条目可能被上游的召回结果共享，修改分数时复制一份
+		rescored := make([]*model.Item, len(items))
+		for i, item := range items {
+			rescored[i] = item
+			if score, ok := resp.Scores[item.ID]; ok {
+				copied := *item
+				copied.Score = score
+				rescored[i] = &copied
+			}
+		}
+		items = rescored
+	}
+	if n.sortByScore {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Score > items[j].Score })
+	}
+
+	ctx.UpdateCandidates(items)
+	ctx.AddLog(fmt.Sprintf("Remote %s (%s) kept %d of %d items", n.stage, n.name, len(items), len(before)))
+}
+
+// truncate 按字符截断过长的文本，用于错误信息
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,173 @@
+package nodes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/workflow"
+)
+
+func newRemoteNode(t *testing.T, url string, config map[string]interface{}) workflow.Node {
+	t.Helper()
+	cfg := map[string]interface{}{"url": url}
+	for k, v := range config {
+		cfg[k] = v
+	}
+	node, err := NewRemoteHTTPNode(workflow.NodeConfig{Name: "scorer", Type: "remote_http", Config: cfg})
+	if err != nil {
+		t.Fatalf("NewRemoteHTTPNode failed: %v", err)
+	}
+	return node
+}
+
+// respondWith 返回固定响应的处理函数，并记录收到的请求
+func respondWith(body string, got *externalRequest, header *http.Header) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		if header != nil {
+			*header = r.Header.Clone()
+		}
+		w.Write([]byte(body))
+	}
+}
+
+func TestRemoteHTTPNodeRank(t *testing.T) {
+	var req externalRequest
+	var header http.Header
+	srv := httptest.NewServer(respondWith(`{
+		"remove": [{"id": "a", "reason": "blocked artist"}],
+		"add": [{"id": "d", "name": "d"}],
+		"scores": {"b": 0.2, "c": 0.9, "d": 0.5},
+		"logs": ["scored 3 items"]
+	}`, &req, &header))
+	defer srv.Close()
+
+	node := newRemoteNode(t, srv.URL, map[string]interface{}{
+		"headers":       map[string]interface{}{"X-Api-Key": "secret"},
+		"params":        map[string]interface{}{"model": "v2"},
+		"sort_by_score": true,
+	})
+	ctx := newExecContext("a", "b", "c")
+	original := ctx.GetCandidates()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if got := candidateIDs(ctx); got != "c,d,b" {
+		t.Errorf("expected c,d,b, got %s", got)
+	}
+	if original[2].Score != 0 {
+		t.Error("rescoring modified the upstream item")
+	}
+	if req.UserID != "u1" || len(req.Candidates) != 3 || len(req.Favorites) != 2 || req.Params["model"] != "v2" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if header.Get("X-Api-Key") != "secret" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if log := strings.Join(ctx.TraceLog, "\n"); !strings.Contains(log, "[scorer] scored 3 items") {
+		t.Errorf("expected remote logs in trace, got %q", log)
+	}
+}
+
+func TestRemoteHTTPNodeStages(t *testing.T) {
+	cases := map[string]struct {
+		stage string
+		body  string
+		want  string // 成功时为候选集，失败时为错误信息
+		err   bool
+	}{
+		"recall":                {"recall", `{"add": [{"id": "x"}]}`, "a,b,x", false},
+		"filter":                {"filter", `{"remove": [{"id": "a"}]}`, "b", false},
+		"rank replaces":         {"rank", `{"candidates": [{"id": "b"}, {"id": "a"}]}`, "b,a", false},
+		"filter cannot add":     {"filter", `{"add": [{"id": "x"}]}`, "'add' is not supported by a filter node", true},
+		"recall cannot rescore": {"recall", `{"scores": {"a": 1}}`, "'scores' is not supported by a recall node", true},
+		"replace and remove":    {"rank", `{"candidates": [], "remove": [{"id": "a"}]}`, "cannot be combined", true},
+		"missing id":            {"rank", `{"add": [{"name": "x"}]}`, "item 0 has no id", true},
+		"reported error":        {"rank", `{"error": "model not loaded"}`, "remote service reported: model not loaded", true},
+		"invalid json":          {"rank", `not json`, "invalid remote response", true},
+	}
+	for name, tc := range cases {
+		srv := httptest.NewServer(respondWith(tc.body, nil, nil))
+		node := newRemoteNode(t, srv.URL, map[string]interface{}{"stage": tc.stage})
+		ctx := newExecContext("a", "b")
+		err := node.Execute(ctx)
+		srv.Close()
+
+		if tc.err {
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if got := candidateIDs(ctx); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", name, tc.want, got)
+		}
+	}
+}
+
+func TestRemoteHTTPNodeRetries(t *testing.T) {
+	// 5xx 重试后成功
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"scores": {"a": 1}}`))
+	}))
+	defer srv.Close()
+	node := newRemoteNode(t, srv.URL, map[string]interface{}{"retries": 2, "retry_backoff_ms": 1})
+	if err := node.Execute(newExecContext("a")); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	// 4xx 不重试
+	atomic.StoreInt32(&calls, 0)
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "unknown model", http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	node = newRemoteNode(t, bad.URL, map[string]interface{}{"retries": 2, "retry_backoff_ms": 1})
+	if err := node.Execute(newExecContext("a")); err == nil || !strings.Contains(err.Error(), "status 400: unknown model") {
+		t.Errorf("expected status error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry on 400, got %d calls", calls)
+	}
+
+	// 超时的请求同样重试，错误信息保留最后一次的原因
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	node = newRemoteNode(t, slow.URL, map[string]interface{}{"call_timeout_ms": 30, "retries": 1, "retry_backoff_ms": 1})
+	if err := node.Execute(newExecContext("a")); err == nil || !strings.Contains(err.Error(), "after 2 attempts: remote call timed out after 30ms") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
+func TestRemoteHTTPNodeConfig(t *testing.T) {
+	for _, url := range []string{"localhost:8080/score", "ftp://scorer/score", "http://"} {
+		_, err := NewRemoteHTTPNode(workflow.NodeConfig{Name: "scorer", Type: "remote_http", Config: map[string]interface{}{"url": url}})
+		if err == nil || !strings.Contains(err.Error(), "config.url") {
+			t.Errorf("%s: expected url error, got %v", url, err)
+		}
+	}
+}
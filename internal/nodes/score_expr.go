@@ -0,0 +1,128 @@
+package nodes
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"recommend_engine/internal/expr"
+	"recommend_engine/internal/model"
+	"recommend_engine/internal/workflow"
+)
+
+// ExprScoreNode 按表达式重新计算条目的分数，表达式在加载配置时编译
+type ExprScoreNode struct {
+	name        string
+	program     *expr.Program
+	when        *expr.Program // 为空时对所有条目生效
+	sortByScore bool
+	onError     string
+}
+
+// ExprScoreConfig score_expr 节点的配置
+type ExprScoreConfig struct {
+	Expr        string `config:"expr" required:"true" min:"1"`             // 新的分数，如 score * 1.2
+	When        string `config:"when"`                                     // 只对成立的条目打分，如 source == 'doubao_recall_1'
+	SortByScore bool   `config:"sort_by_score"`                            // 打分后按分数降序排列
+	OnError     string `config:"on_error" default:"keep" enum:"keep,fail"` // 求值出错时保留原分数或使节点失败
+}
+
+func init() {
+	Register(Spec{
+		Type:        "score_expr",
+		Stage:       "rank",
+		Description: "按表达式重新打分，如 when source == 'x' 时 score * 1.2",
+		Config:      ExprScoreConfig{},
+		Build: func(cfg workflow.NodeConfig, deps *Deps) (workflow.Node, error) {
+			return NewExprScoreNode(cfg)
+		},
+	})
+}
+
+func NewExprScoreNode(cfg workflow.NodeConfig) (workflow.Node, error) {
+	var c ExprScoreConfig
+	if err := cfg.Decode(&c); err != nil {
+		return nil, err
+	}
+	program, err := compileExpr("expr", c.Expr)
+	if err != nil {
+		return nil, err
+	}
+	n := &ExprScoreNode{
+		name:        cfg.Name,
+		program:     program,
+		sortByScore: c.SortByScore,
+		onError:     c.OnError,
+	}
+	if c.When != "" {
+		if n.when, err = compileExpr("when", c.When); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (n *ExprScoreNode) Name() string { return n.name }
+func (n *ExprScoreNode) Type() string { return "rank" }
+
+func (n *ExprScoreNode) Execute(ctx *workflow.Context) error {
+	candidates := ctx.GetCandidates()
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	base := requestVars(ctx)
+	result := make([]*model.Item, len(candidates))
+	var scored, failed int
+	var firstErr error
+	for i, item := range candidates {
+		result[i] = item
+		score, ok, err := n.score(itemVars(base, item, i))
+		if err != nil {
+			if n.onError == "fail" {
+				return fmt.Errorf("failed to score item '%s': %w", item.ID, err)
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("item '%s': %w", item.ID, err)
+			}
+			failed++
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// 条目可能被上游的召回结果共享，修改分数时复制一份
+		copied := *item
+		copied.Score = score
+		result[i] = &copied
+		scored++
+	}
+	if n.sortByScore {
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	}
+
+	ctx.UpdateCandidates(result)
+	if failed > 0 {
+		ctx.AddLog(fmt.Sprintf("Expression score (%s) failed on %d items, first error: %v", n.name, failed, firstErr))
+	}
+	ctx.AddLog(fmt.Sprintf("Expression score (%s) rescored %d of %d items", n.name, scored, len(candidates)))
+	return nil
+}
+
+// score 计算条目的新分数，when 不成立时返回 false
+func (n *ExprScoreNode) score(vars map[string]interface{}) (float64, bool, error) {
+	if n.when != nil {
+		ok, err := n.when.EvalBool(vars)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+	}
+	score, err := n.program.EvalNumber(vars)
+	if err != nil {
+		return 0, false, err
+	}
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, false, fmt.Errorf("expression produced %v", score)
+	}
+	return score, true, nil
+}
@@ -0,0 +1,49 @@
+package nodes
+
+import (
+	"strings"
+	"testing"
+
+	"recommend_engine/internal/workflow"
+)
+
+func TestExprScoreNode(t *testing.T) {
+	node, err := NewExprScoreNode(workflow.NodeConfig{Name: "boost", Type: "score_expr", Config: map[string]interface{}{
+		"expr":          "score * 1.2 + 2",
+		"when":          "source == 'doubao_recall_1'",
+		"sort_by_score": true,
+	}})
+	if err != nil {
+		t.Fatalf("NewExprScoreNode failed: %v", err)
+	}
+	ctx := newExprContext()
+	original := ctx.GetCandidates()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := candidateIDs(ctx); got != "a,f1,b" {
+		t.Errorf("expected a,f1,b, got %s", got)
+	}
+	if got := ctx.GetCandidates()[0].Score; got != 3.2 {
+		t.Errorf("expected boosted score 3.2, got %v", got)
+	}
+	if original[0].Score != 1 {
+		t.Error("rescoring modified the upstream item")
+	}
+
+	// 求值出错的条目保留原分数，并记录日志
+	node, _ = NewExprScoreNode(workflow.NodeConfig{Name: "by_year", Type: "score_expr", Config: map[string]interface{}{
+		"expr": "(meta_data.year - 1900) / 100",
+	}})
+	ctx = newExprContext()
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	items := ctx.GetCandidates()
+	if items[0].Score != 0.85 || items[2].Score != 3 {
+		t.Errorf("unexpected scores: %v, %v", items[0].Score, items[2].Score)
+	}
+	if log := strings.Join(ctx.TraceLog, "\n"); !strings.Contains(log, "failed on 1 items, first error: item 'f1'") {
+		t.Errorf("expected evaluation failure in trace log, got %q", log)
+	}
+}
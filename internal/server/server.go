@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
+	"sync/atomic"
 
 	"recommend_engine/internal/history"
 	"recommend_engine/internal/model"
+	"recommend_engine/internal/nodes"
 	taskpkg "recommend_engine/internal/task" // 使用别名导入以避免命名冲突
 	"recommend_engine/internal/user"
 	"recommend_engine/internal/workflow"
@@ -16,6 +17,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// statusClientClosedRequest 是客户端在响应返回前断开连接时使用的非标准状态码 (nginx 约定)
+const statusClientClosedRequest = 499
+
 // Server 代表 HTTP API 服务器
 type Server struct {
 	router       *gin.Engine
@@ -23,6 +27,9 @@ type Server struct {
 	engine       *workflow.Engine
 	historyStore history.Store
 	taskManager  *taskpkg.Manager // 使用别名
+
+	httpServer   *http.Server
+	shuttingDown int32 // 开始优雅退出后置为 1，/readyz 返回 503
 }
 
 // NewServer 创建新的 HTTP 服务器
@@ -56,11 +63,26 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 }
 
 // Run 启动服务器
+// 调用 Shutdown 后返回 http.ErrServerClosed
 func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+	s.httpServer = &http.Server{Addr: addr, Handler: s.router}
+	return s.httpServer.ListenAndServe()
+}
+
+// Shutdown 优雅退出：/readyz 立即返回 503，停止接受新连接并等待进行中的请求完成
+// 后台执行的异步任务不在此等待，由 Engine.Close 等待其结束
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
 }
 
 func (s *Server) setupRoutes() {
+	// 就绪检查，不需要鉴权，供负载均衡和编排系统探测
+	s.router.GET("/readyz", s.handleReadyz)
+
 	v1 := s.router.Group("/api/v1")
 
 	// 中间件：Token 鉴权
@@ -70,6 +92,65 @@ func (s *Server) setupRoutes() {
 	v1.POST("/recommend/:scene", s.handleRecommend)
 	// 异步任务结果查询接口
 	v1.GET("/recommend/result/:task_id", s.handleGetResult)
+
+	// 管理接口，会暴露流程配置 (URL、llm_config_key、表达式等)，只允许管理员访问
+	admin := v1.Group("/admin")
+	admin.Use(s.adminMiddleware())
+	admin.GET("/pipelines/:scene/graph", s.handlePipelineGraph)
+	admin.GET("/shadow", s.handleShadowStats)
+	admin.GET("/nodes", s.handleNodeCatalog)
+}
+
+// handleNodeCatalog 返回可以在流程配置中使用的节点类型及其配置项
+// GET /api/v1/admin/nodes
+func (s *Server) handleNodeCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"nodes": nodes.Catalog()})
+}
+
+// handleShadowStats 返回各场景影子流程的累计统计
+// GET /api/v1/admin/shadow
+func (s *Server) handleShadowStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"shadow": s.engine.ShadowStats()})
+}
+
+// handleReadyz 返回服务是否可以接收流量，以及节点预热的耗时
+// GET /readyz
+func (s *Server) handleReadyz(c *gin.Context) {
+	r := s.engine.Readiness()
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		r.Ready = false
+		r.Reason = "server is shutting down"
+	}
+	status := http.StatusOK
+	if !r.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, r)
+}
+
+// handlePipelineGraph 以 DOT 或 Mermaid 格式返回场景的流程图
+// GET /api/v1/admin/pipelines/:scene/graph?format=mermaid&latency=true
+// latency=true 时附带该场景最近一次执行中每个节点的耗时
+func (s *Server) handlePipelineGraph(c *gin.Context) {
+	scene := c.Param("scene")
+	cfg, ok := s.engine.PipelineConfig(scene)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("scene '%s' not supported", scene)})
+		return
+	}
+
+	format := c.DefaultQuery("format", workflow.GraphMermaid)
+	var trace *workflow.Span
+	if c.Query("latency") == "true" {
+		trace = s.engine.LastTrace(scene)
+	}
+
+	graph, err := workflow.RenderGraph(scene, cfg, format, trace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.String(http.StatusOK, graph)
 }
 
 // handleGetResult 处理获取异步任务结果的请求
@@ -88,22 +169,28 @@ func (s *Server) handleGetResult(c *gin.Context) {
 	}
 
 	// 根据任务状态返回不同的响应
+	var resp gin.H
 	switch task.Status {
 	case "completed":
-		c.JSON(http.StatusOK, gin.H{
+		resp = gin.H{
 			"status": task.Status,
 			"data":   task.Result,
-		})
+		}
 	case "failed":
-		c.JSON(http.StatusOK, gin.H{
+		resp = gin.H{
 			"status": task.Status,
 			"error":  task.Error,
-		})
+		}
 	default: // "pending" or "processing"
-		c.JSON(http.StatusOK, gin.H{
+		resp = gin.H{
 			"status": task.Status,
-		})
+		}
+	}
+	// debug 模式下附带执行轨迹
+	if c.Query("debug") == "true" && task.Trace != nil {
+		resp["trace"] = task.Trace
 	}
+	c.JSON(http.StatusOK, resp)
 }
 // authMiddleware 鉴权中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
@@ -133,9 +220,34 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 	}
 }
 
+// adminMiddleware 要求鉴权用户具有管理员权限，需放在 authMiddleware 之后
+func (s *Server) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uVal, _ := c.Get("user")
+		if u, ok := uVal.(*model.User); !ok || !u.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin privileges required"})
+			return
+		}
+		c.Next()
+	}
+}
+
 type RecommendRequest struct {
 	// Scene     string   `json:"scene"` // 移除 Scene 字段，改用 URL Path 参数
 	Favorites []string `json:"favorites" binding:"required"`
+	// Params 请求级参数，会写入 workflow.Context.Config，供 switch 等节点使用
+	Params map[string]interface{} `json:"params"`
+}
+
+// buildWorkflowConfig 构建请求级的 Context.Config
+// domain 固定为 scene，不允许被 params 覆盖
+func buildWorkflowConfig(scene string, params map[string]interface{}) map[string]interface{} {
+	cfg := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		cfg[k] = v
+	}
+	cfg["domain"] = scene
+	return cfg
 }
 
 // handleRecommend 处理推荐请求
@@ -171,8 +283,9 @@ func (s *Server) handleRecommend(c *gin.Context) {
 		Favorites: req.Favorites,
 	}
 
-	// 5. 检查是同步还是异步执行
+	// 5. 检查是同步还是异步执行，以及是否返回执行轨迹
 	isAsync := c.Query("async") == "true"
+	debug := c.Query("debug") == "true"
 
 	if isAsync {
 		// --- 异步执行路径 ---
@@ -184,20 +297,23 @@ func (s *Server) handleRecommend(c *gin.Context) {
 			s.taskManager.UpdateStatus(task.ID, taskpkg.StatusProcessing)
 
 			// 5.1 准备 Workflow Context (后台)
-			// 使用独立的后台 context，并设置一个较长的超时时间（例如5分钟）作为兜底
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-			defer cancel()
-
-			wfCtx := workflow.NewContext(ctx, requestUser.ID, requestUser)
-			wfCtx.Config = map[string]interface{}{"domain": scene}
-
-			// 6. 执行推荐 (后台)
-			if err := s.engine.Run(wfCtx, scene); err != nil {
+			// 使用独立的后台 context，超时时间由 Pipeline 的 timeout_ms 决定
+			wfCtx := workflow.NewContext(context.Background(), requestUser.ID, requestUser)
+			wfCtx.Config = buildWorkflowConfig(scene, req.Params)
+			wfCtx.Debug = debug
+
+			// 6. 执行推荐 (后台)，无论成功与否都记录执行轨迹
+			err := s.engine.Run(wfCtx, scene)
+			if trace := wfCtx.Trace(); trace != nil {
+				s.taskManager.SetTrace(task.ID, trace)
+			}
+			if err != nil {
 				s.taskManager.SetError(task.ID, err)
 				return
 			}
 
 			candidates := wfCtx.GetCandidates()
+			experiments := wfCtx.Experiments()
 
 			// 7. 异步保存历史 (后台)
 			var itemNames []string
@@ -205,26 +321,32 @@ func (s *Server) handleRecommend(c *gin.Context) {
 				itemNames = append(itemNames, item.Name)
 			}
 			if len(itemNames) > 0 {
-				if err := s.historyStore.SaveHistory(u.ID, scene, itemNames); err != nil {
+				if err := s.historyStore.SaveHistory(u.ID, scene, itemNames, experiments); err != nil {
 					// 即使历史保存失败，也应将推荐结果标记为成功
 					fmt.Printf("Warning: Failed to save history for task %s: %v\n", task.ID, err)
 				}
 			}
 			
 			// 8. 将最终结果存入任务
-			s.taskManager.SetResult(task.ID, gin.H{
+			result := gin.H{
 				"scene": scene,
 				"items": candidates,
-			})
+			}
+			if experiments != nil {
+				result["experiments"] = experiments
+			}
+			if debug {
+				result["snapshots"] = wfCtx.Snapshots()
+			}
+			s.taskManager.SetResult(task.ID, result)
 		}()
 	} else {
 		// --- 同步执行路径 (保持原有逻辑不变) ---
 		// 5. 准备 Workflow Context
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 300*time.Second)
-		defer cancel()
-
-		wfCtx := workflow.NewContext(ctx, requestUser.ID, requestUser)
-		wfCtx.Config = map[string]interface{}{"domain": scene}
+		// 超时时间由 Pipeline 的 timeout_ms 决定，客户端断开时同样会取消
+		wfCtx := workflow.NewContext(c.Request.Context(), requestUser.ID, requestUser)
+		wfCtx.Config = buildWorkflowConfig(scene, req.Params)
+		wfCtx.Debug = debug
 
 		// 6. 执行推荐
 		if err := s.engine.Run(wfCtx, scene); err != nil {
@@ -232,11 +354,27 @@ func (s *Server) handleRecommend(c *gin.Context) {
 				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("scene '%s' not supported", scene)})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("recommendation failed: %v", err)})
+			// 超时返回 504；客户端断开导致的取消返回 499 (客户端通常已经收不到响应，主要用于访问日志)
+			status := http.StatusInternalServerError
+			resp := gin.H{"error": fmt.Sprintf("recommendation failed: %v", err)}
+			switch {
+			case workflow.IsDeadlineExceeded(err):
+				status = http.StatusGatewayTimeout
+				resp["error"] = fmt.Sprintf("recommendation timed out: %v", err)
+			case workflow.IsCanceled(err):
+				status = statusClientClosedRequest
+				resp["error"] = fmt.Sprintf("recommendation canceled: %v", err)
+			}
+			if debug {
+				resp["trace"] = wfCtx.Trace()
+				resp["snapshots"] = wfCtx.Snapshots()
+			}
+			c.JSON(status, resp)
 			return
 		}
 
 		candidates := wfCtx.GetCandidates()
+		experiments := wfCtx.Experiments()
 
 		// 7. 异步保存历史
 		go func() {
@@ -245,15 +383,23 @@ func (s *Server) handleRecommend(c *gin.Context) {
 				itemNames = append(itemNames, item.Name)
 			}
 			if len(itemNames) > 0 {
-				if err := s.historyStore.SaveHistory(u.ID, scene, itemNames); err != nil {
+				if err := s.historyStore.SaveHistory(u.ID, scene, itemNames, experiments); err != nil {
 					fmt.Printf("Failed to save history async: %v\n", err)
 				}
 			}
 		}()
 
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"scene": scene,
 			"items": candidates,
-		})
+		}
+		if experiments != nil {
+			resp["experiments"] = experiments
+		}
+		if debug {
+			resp["trace"] = wfCtx.Trace()
+			resp["snapshots"] = wfCtx.Snapshots()
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"recommend_engine/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeProvider map[string]*model.User // token -> user
+
+func (p fakeProvider) GetUser(userID string) (*model.User, error) {
+	for _, u := range p {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (p fakeProvider) GetUserByToken(token string) (*model.User, error) {
+	if u, ok := p[token]; ok {
+		return u, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(fakeProvider{
+		"sk-alice": {ID: "alice"},
+		"sk-ops":   {ID: "ops", Admin: true},
+	}, nil, nil, nil)
+
+	cases := map[string]int{
+		"":         http.StatusUnauthorized,
+		"sk-bad":   http.StatusUnauthorized,
+		"sk-alice": http.StatusForbidden,
+		"sk-ops":   http.StatusOK,
+	}
+	for token, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/nodes", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("token %q: expected %d, got %d: %s", token, want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -24,6 +24,7 @@ type Task struct {
 	Status    Status      `json:"status"`
 	Result    interface{} `json:"result,omitempty"`
 	Error     string      `json:"error,omitempty"`
+	Trace     interface{} `json:"trace,omitempty"` // 结构化执行轨迹，成功和失败时都会记录
 	CreatedAt time.Time   `json:"created_at"`
 }
 
@@ -107,3 +108,16 @@ func (m *Manager) SetError(id string, err error) error {
 	task.Status = StatusFailed
 	return nil
 }
+
+// SetTrace records the execution trace of a task.
+func (m *Manager) SetTrace(id string, trace interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, exists := m.tasks[id]
+	if !exists {
+		return fmt.Errorf("task with ID '%s' not found", id)
+	}
+	task.Trace = trace
+	return nil
+}
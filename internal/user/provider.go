@@ -3,6 +3,7 @@ package user
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	"recommend_engine/internal/model"
@@ -27,6 +28,15 @@ type staticConfig struct {
 	Users []model.User `yaml:"users"`
 }
 
+// ConfigError 汇总用户配置中的所有问题
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid user config: " + strings.Join(e.Problems, "; ")
+}
+
 // NewStaticProvider 创建一个新的 StaticProvider 实例
 // configPath 是用户配置文件的路径 (yaml格式)
 func NewStaticProvider(configPath string) (*StaticProvider, error) {
@@ -42,18 +52,34 @@ func NewStaticProvider(configPath string) (*StaticProvider, error) {
 
 	userMap := make(map[string]*model.User)
 	tokenIndex := make(map[string]*model.User)
+	var problems []string
 
 	for i := range config.Users {
 		u := config.Users[i]
 		// 注意：这里需要深拷贝或取地址，确保 map 指向正确的数据
 		// 由于 u 是循环变量，在 Go 1.22 之前如果直接取地址会有问题，但 config.Users[i] 安全
 		userPtr := &config.Users[i]
+
+		// 重复的 ID 或 Token 会让后面的用户覆盖前面的用户，收集所有问题后一并报告
+		if u.ID == "" {
+			problems = append(problems, fmt.Sprintf("users[%d]: id is empty", i))
+		} else if _, dup := userMap[u.ID]; dup {
+			problems = append(problems, fmt.Sprintf("users[%d]: duplicate id '%s'", i, u.ID))
+		}
+		if u.Token != "" {
+			if other, dup := tokenIndex[u.Token]; dup {
+				problems = append(problems, fmt.Sprintf("users[%d]: token already used by user '%s'", i, other.ID))
+			}
+		}
+
 		userMap[u.ID] = userPtr
-		
 		if u.Token != "" {
 			tokenIndex[u.Token] = userPtr
 		}
 	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
 
 	return &StaticProvider{
 		users:      userMap,
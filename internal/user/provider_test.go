@@ -1,6 +1,9 @@
 package user
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"recommend_engine/internal/model"
@@ -54,3 +57,38 @@ func TestStaticProvider(t *testing.T) {
 		t.Error("Expected error for non-existent user")
 	}
 }
+
+func TestStaticProviderRejectsDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	content := `users:
+  - id: "u1"
+    token: "t1"
+  - id: "u1"
+    token: "t2"
+  - id: "u2"
+    token: "t1"
+  - id: ""
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := NewStaticProvider(path)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	want := []string{
+		"users[1]: duplicate id 'u1'",
+		"users[2]: token already used by user 'u1'",
+		"users[3]: id is empty",
+	}
+	if len(cfgErr.Problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), cfgErr.Problems)
+	}
+	for i := range want {
+		if cfgErr.Problems[i] != want[i] {
+			t.Errorf("problem %d: expected %q, got %q", i, want[i], cfgErr.Problems[i])
+		}
+	}
+}
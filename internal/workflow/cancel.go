@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CanceledError 表示流程因 ctx.Ctx 结束 (客户端断开、Pipeline 超时等) 而提前终止
+// 剩余的节点不会再执行
+type CanceledError struct {
+	Node string // 检测到取消时正在执行或即将执行的节点
+	Err  error  // context.Canceled 或 context.DeadlineExceeded
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("pipeline canceled at node %s: %v", e.Node, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// IsCanceled 判断错误是否由流程被取消导致
+func IsCanceled(err error) bool {
+	var ce *CanceledError
+	return errors.As(err, &ce)
+}
+
+// IsDeadlineExceeded 判断流程是否因超时 (而不是调用方主动取消) 被终止
+func IsDeadlineExceeded(err error) bool {
+	var ce *CanceledError
+	return errors.As(err, &ce) && errors.Is(ce.Err, context.DeadlineExceeded)
+}
+
+// checkCanceled 在执行节点前检查 ctx 是否已经结束
+func checkCanceled(ctx *Context, node Node) error {
+	if err := ctx.Ctx.Err(); err != nil {
+		return &CanceledError{Node: node.Name(), Err: err}
+	}
+	return nil
+}
+
+// canceledResult 规范化节点返回的错误
+//   - ctx 已经结束：返回 CanceledError，内层节点已经报告的 CanceledError 直接透传
+//   - ctx 仍然有效：内层 CanceledError 来自子流程或节点自身的超时，对当前层级而言只是节点失败，
+//     转换为普通错误，避免外层 (如 fallback) 误以为整个请求已被取消
+func canceledResult(ctx *Context, node Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *CanceledError
+	if cause := ctx.Ctx.Err(); cause != nil {
+		if errors.As(err, &ce) {
+			return ce
+		}
+		return &CanceledError{Node: node.Name(), Err: cause}
+	}
+	if errors.As(err, &ce) {
+		return errors.New(err.Error())
+	}
+	return err
+}
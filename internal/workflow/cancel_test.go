@@ -0,0 +1,91 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+)
+
+func TestCancellationStopsPipeline(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"nodes": [
+					{"name": "group", "type": "parallel", "nodes": [
+						{"name": "hang", "type": "stub", "config": {"block": true}},
+						{"name": "fast", "type": "stub", "config": {"items": ["a"]}}
+					]},
+					{"name": "after", "type": "stub", "config": {"items": ["b"]}}
+				]
+			},
+			"slow": {
+				"timeout_ms": 50,
+				"nodes": [{"name": "hang", "type": "stub", "config": {"block": true}}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// 客户端断开：并行节点不再按策略判定成功，后续节点不会执行
+	runCtx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	ctx := NewContext(runCtx, "u1", &model.User{ID: "u1"})
+	err = engine.Run(ctx, "music")
+	if !IsCanceled(err) || IsDeadlineExceeded(err) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	for _, name := range candidateNames(ctx) {
+		if name == "b" {
+			t.Error("node after cancellation should not run")
+		}
+	}
+	if children := ctx.Trace().Children; len(children) != 1 {
+		t.Errorf("expected only the parallel node in the trace, got %d spans", len(children))
+	}
+
+	// 已经取消的请求不会执行任何节点
+	ctx = NewContext(runCtx, "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); !IsCanceled(err) || len(ctx.GetCandidates()) != 0 {
+		t.Errorf("expected no work after cancellation, got %v with %v", err, candidateNames(ctx))
+	}
+
+	// Pipeline 超时
+	ctx = NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "slow"); !IsDeadlineExceeded(err) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestSubPipelineTimeoutIsNodeFailure(t *testing.T) {
+	// 子流程自身超时对外层而言只是节点失败，fallback 应当继续尝试下一个子节点
+	path := writeConfig(t, `{
+		"pipelines": {
+			"slow": {
+				"timeout_ms": 50,
+				"nodes": [{"name": "hang", "type": "stub", "config": {"block": true}}]
+			},
+			"music": {
+				"nodes": [{"name": "recall", "type": "fallback", "nodes": [
+					{"name": "primary", "type": "pipeline", "config": {"pipeline": "slow"}},
+					{"name": "backup", "type": "stub", "config": {"items": ["a"]}}
+				]}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if got := candidateNames(ctx); len(got) != 1 || got[0] != "a" {
+		t.Errorf("expected [a], got %v", got)
+	}
+}
@@ -0,0 +1,191 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Condition 是 switch 节点中的一个判断条件
+// Field 支持:
+//   - user.id / user.name / user.favorites_count / user.favorites
+//   - candidates_count: 当前候选集大小
+//   - recall_count: 召回结果总数
+//   - config.<key>: 请求级配置 (Context.Config)，如 config.domain
+type Condition struct {
+	Field string      `json:"field" yaml:"field"`
+	Op    string      `json:"op" yaml:"op"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// 条件支持的操作符
+var conditionOps = map[string]bool{
+	"eq": true, "ne": true,
+	"gt": true, "gte": true, "lt": true, "lte": true,
+	"in": true, "contains": true,
+	"exists": true, "not_exists": true,
+}
+
+// validate 在加载时检查条件是否合法
+func (c Condition) validate() error {
+	switch c.Field {
+	case "user.id", "user.name", "user.favorites_count", "user.favorites", "candidates_count", "recall_count":
+	default:
+		if !strings.HasPrefix(c.Field, "config.") || c.Field == "config." {
+			return fmt.Errorf("unknown condition field: %q", c.Field)
+		}
+	}
+
+	if !conditionOps[c.Op] {
+		return fmt.Errorf("unknown condition op %q for field %s", c.Op, c.Field)
+	}
+	if c.Op == "in" {
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("condition op 'in' for field %s requires a list value", c.Field)
+		}
+	}
+	if c.Op != "exists" && c.Op != "not_exists" && c.Value == nil {
+		return fmt.Errorf("condition op %q for field %s requires a value", c.Op, c.Field)
+	}
+	return nil
+}
+
+// resolve 从 Context 中取出字段的值，字段不存在时返回 false
+func (c Condition) resolve(ctx *Context) (interface{}, bool) {
+	switch c.Field {
+	case "user.id":
+		return ctx.UserID, true
+	case "user.name":
+		if ctx.User == nil {
+			return nil, false
+		}
+		return ctx.User.Name, true
+	case "user.favorites_count":
+		if ctx.User == nil {
+			return 0, true
+		}
+		return len(ctx.User.Favorites), true
+	case "user.favorites":
+		if ctx.User == nil {
+			return nil, false
+		}
+		favorites := make([]interface{}, len(ctx.User.Favorites))
+		for i, f := range ctx.User.Favorites {
+			favorites[i] = f
+		}
+		return favorites, true
+	case "candidates_count":
+		return len(ctx.GetCandidates()), true
+	case "recall_count":
+		total := 0
+		for _, items := range ctx.GetRecallResults() {
+			total += len(items)
+		}
+		return total, true
+	}
+
+	v, ok := ctx.Config[strings.TrimPrefix(c.Field, "config.")]
+	return v, ok && v != nil
+}
+
+// Match 判断条件在当前 Context 下是否成立
+// 类型不兼容的比较 (如字符串与数字比较大小) 视为不成立
+func (c Condition) Match(ctx *Context) bool {
+	actual, exists := c.resolve(ctx)
+	switch c.Op {
+	case "exists":
+		return exists
+	case "not_exists":
+		return !exists
+	}
+	if !exists {
+		return c.Op == "ne"
+	}
+
+	switch c.Op {
+	case "eq":
+		return valuesEqual(actual, c.Value)
+	case "ne":
+		return !valuesEqual(actual, c.Value)
+	case "in":
+		for _, v := range c.Value.([]interface{}) {
+			if valuesEqual(actual, v) {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		if list, ok := actual.([]interface{}); ok {
+			for _, v := range list {
+				if valuesEqual(v, c.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		s, ok1 := actual.(string)
+		sub, ok2 := c.Value.(string)
+		return ok1 && ok2 && strings.Contains(s, sub)
+	}
+
+	cmp, ok := compareValues(actual, c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case "gt":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "lte":
+		return cmp <= 0
+	}
+	return false
+}
+
+// toNumber 将 JSON/YAML 中可能出现的数字类型统一为 float64
+func toNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		return ok && na == nb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues 比较两个数字或两个字符串，返回 -1/0/1
+func compareValues(a, b interface{}) (int, bool) {
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case na < nb:
+			return -1, true
+		case na > nb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, ok1 := a.(string)
+	sb, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
@@ -0,0 +1,443 @@
+package workflow
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigError 描述配置中某个具体位置的错误
+// Path 形如 pipelines.music.nodes[1].config.lookback_days
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors 汇总加载配置时发现的多个错误，每个元素通常是 *ConfigError
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// append 追加错误，ConfigErrors 会被展开
+func (e ConfigErrors) append(err error) ConfigErrors {
+	if errs, ok := err.(ConfigErrors); ok {
+		return append(e, errs...)
+	}
+	return append(e, err)
+}
+
+// orNil 返回汇总后的错误：没有错误时返回 nil，只有一个错误时直接返回它
+func (e ConfigErrors) orNil() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	default:
+		return e
+	}
+}
+
+// withPath 为错误添加路径前缀，err 为 nil 时返回 nil
+// 已经带路径的 ConfigError 会在原路径前拼接 prefix，ConfigErrors 中的每个错误都会添加前缀
+func withPath(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errs, ok := err.(ConfigErrors); ok {
+		result := make(ConfigErrors, len(errs))
+		for i, e := range errs {
+			result[i] = withPath(prefix, e)
+		}
+		return result
+	}
+	if ce, ok := err.(*ConfigError); ok {
+		return &ConfigError{Path: joinPath(prefix, ce.Path), Err: ce.Err}
+	}
+	return &ConfigError{Path: prefix, Err: err}
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
+
+// Decode 将节点的 config 解码到 out 指向的结构体，错误路径以 config 开头
+// 节点工厂应当直接返回该错误，加载时会补全节点所在位置
+func (c NodeConfig) Decode(out interface{}) error {
+	return withPath("config", DecodeConfig(c.Config, out))
+}
+
+// DecodeConfig 将配置解码到 out 指向的结构体
+//
+// 字段通过 struct tag 声明：
+//   - config:"name"      配置中的键名，没有该 tag 的字段会被忽略
+//   - default:"value"    键缺失时使用的默认值 (仅支持标量类型)
+//   - required:"true"    键必须存在
+//   - min:"n" / max:"n"  数值的取值范围；对字符串、列表、映射则限制长度
+//   - enum:"a,b,c"       字符串的可选值
+//
+// 支持的字段类型：string、bool、整数、浮点数、interface{}，以及由它们组成的
+// 切片、以 string 为键的映射和嵌套结构体。未声明的键、类型不匹配 (如整数字段
+// 收到字符串 "30" 或小数 1.5) 都会返回带路径的 *ConfigError；有多处错误时
+// 返回包含所有错误的 ConfigErrors。
+func DecodeConfig(raw map[string]interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target must be a pointer to struct, got %T", out)
+	}
+	return decodeStruct(raw, rv.Elem())
+}
+
+// decodeStruct 解码结构体的所有字段，一次返回所有字段中的错误
+func decodeStruct(raw map[string]interface{}, v reflect.Value) error {
+	t := v.Type()
+	known := make(map[string]bool, t.NumField())
+	var errs ConfigErrors
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("config")
+		if key == "" || key == "-" {
+			continue
+		}
+		known[key] = true
+
+		value, present := raw[key]
+		if present && value == nil {
+			present = false
+		}
+		if !present {
+			if field.Tag.Get("required") == "true" {
+				errs = append(errs, &ConfigError{Path: key, Err: fmt.Errorf("required field is missing")})
+			} else if def, ok := field.Tag.Lookup("default"); ok {
+				if err := setDefault(v.Field(i), def); err != nil {
+					errs = append(errs, &ConfigError{Path: key, Err: fmt.Errorf("invalid default %q: %w", def, err)})
+				}
+			}
+			continue
+		}
+
+		if err := decodeValue(value, v.Field(i)); err != nil {
+			errs = errs.append(withPath(key, err))
+			continue
+		}
+		if err := checkConstraints(field, v.Field(i)); err != nil {
+			errs = append(errs, &ConfigError{Path: key, Err: err})
+		}
+	}
+
+	// 按键名排序，保证有多个未知键时报告的错误是确定的
+	for _, key := range sortedKeys(raw) {
+		if !known[key] {
+			errs = append(errs, &ConfigError{Path: key, Err: fmt.Errorf("unknown field")})
+		}
+	}
+	return errs.orNil()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeValue 将 JSON 解码得到的值赋给 v，类型不匹配时返回错误
+// 列表、映射中的 null 保留为对应类型的零值
+func decodeValue(value interface{}, v reflect.Value) error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(value))
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return typeError("string", value)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeError("boolean", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := toFloat(value)
+		if !ok {
+			return typeError("integer", value)
+		}
+		if f != math.Trunc(f) || v.OverflowInt(int64(f)) {
+			return fmt.Errorf("expected integer, got %v", f)
+		}
+		v.SetInt(int64(f))
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return typeError("number", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return typeError("list", value)
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		var errs ConfigErrors
+		for i, elem := range list {
+			if err := decodeValue(elem, s.Index(i)); err != nil {
+				errs = errs.append(withPath(fmt.Sprintf("[%d]", i), err))
+			}
+		}
+		if len(errs) > 0 {
+			return errs.orNil()
+		}
+		v.Set(s)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", v.Type())
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError("object", value)
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(obj))
+		var errs ConfigErrors
+		for _, key := range sortedKeys(obj) {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(obj[key], ev); err != nil {
+				errs = errs.append(withPath(key, err))
+				continue
+			}
+			m.SetMapIndex(reflect.ValueOf(key), ev)
+		}
+		if len(errs) > 0 {
+			return errs.orNil()
+		}
+		v.Set(m)
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError("object", value)
+		}
+		return decodeStruct(obj, v)
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+// setDefault 将 tag 中的默认值赋给标量字段
+func setDefault(v reflect.Value, def string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("defaults are not supported for %s", v.Type())
+	}
+	return nil
+}
+
+// checkConstraints 校验 min / max / enum
+func checkConstraints(field reflect.StructField, v reflect.Value) error {
+	var n float64
+	what := "value"
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map:
+		n = float64(v.Len())
+		what = "length"
+	}
+
+	if s, ok := field.Tag.Lookup("min"); ok {
+		min, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid min tag %q", s)
+		}
+		if n < min {
+			return fmt.Errorf("%s must be at least %s, got %v", what, s, n)
+		}
+	}
+	if s, ok := field.Tag.Lookup("max"); ok {
+		max, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid max tag %q", s)
+		}
+		if n > max {
+			return fmt.Errorf("%s must be at most %s, got %v", what, s, n)
+		}
+	}
+	if s, ok := field.Tag.Lookup("enum"); ok && v.Kind() == reflect.String {
+		options := strings.Split(s, ",")
+		for _, option := range options {
+			if v.String() == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s], got %q", strings.Join(options, ", "), v.String())
+	}
+	return nil
+}
+
+// toFloat 接受 JSON 解码得到的 float64 以及其他格式 (如 YAML) 解码得到的整数
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func typeError(expected string, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return fmt.Errorf("expected %s, got string %q", expected, v)
+	case []interface{}:
+		return fmt.Errorf("expected %s, got list", expected)
+	case map[string]interface{}:
+		return fmt.Errorf("expected %s, got object", expected)
+	default:
+		return fmt.Errorf("expected %s, got %T %v", expected, value, value)
+	}
+}
+
+// ConfigField 描述配置结构体中的一个字段，由 DescribeConfig 根据 struct tag 生成
+type ConfigField struct {
+	Key      string        `json:"key"`
+	Type     string        `json:"type"` // string / boolean / integer / number / list<T> / map<T> / object / any
+	Required bool          `json:"required,omitempty"`
+	Default  string        `json:"default,omitempty"`
+	Min      string        `json:"min,omitempty"`
+	Max      string        `json:"max,omitempty"`
+	Enum     []string      `json:"enum,omitempty"`
+	Fields   []ConfigField `json:"fields,omitempty"` // 嵌套结构体的字段
+}
+
+// DescribeConfig 返回配置结构体 (或指向它的指针) 中各字段的描述，与 DecodeConfig 使用同一套 tag
+func DescribeConfig(v interface{}) []ConfigField {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return describeStruct(t)
+}
+
+func describeStruct(t reflect.Type) []ConfigField {
+	var fields []ConfigField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("config")
+		if key == "" || key == "-" {
+			continue
+		}
+		f := ConfigField{
+			Key:      key,
+			Type:     describeType(field.Type),
+			Required: field.Tag.Get("required") == "true",
+			Default:  field.Tag.Get("default"),
+			Min:      field.Tag.Get("min"),
+			Max:      field.Tag.Get("max"),
+		}
+		if s, ok := field.Tag.Lookup("enum"); ok {
+			f.Enum = strings.Split(s, ",")
+		}
+		if field.Type.Kind() == reflect.Struct {
+			f.Fields = describeStruct(field.Type)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// describeType 返回字段类型在配置中的名称，与类型不匹配时的错误信息一致
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Interface:
+		return "any"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list<" + describeType(t.Elem()) + ">"
+	case reflect.Map:
+		return "map<" + describeType(t.Elem()) + ">"
+	case reflect.Struct:
+		return "object"
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,262 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"recommend_engine/internal/model"
+)
+
+type testNodeConfig struct {
+	Order        string            `config:"order" default:"shuffle" enum:"shuffle,desc,asc"`
+	Limit        int               `config:"limit" min:"0" max:"100"`
+	LookbackDays int               `config:"lookback_days" default:"7" min:"1"`
+	Ratio        float64           `config:"ratio" default:"0.5"`
+	Key          string            `config:"key" required:"true"`
+	Items        []string          `config:"items"`
+	Headers      map[string]string `config:"headers"`
+}
+
+func TestDecodeConfig(t *testing.T) {
+	var c testNodeConfig
+	err := DecodeConfig(map[string]interface{}{
+		"key":     "doubao",
+		"limit":   float64(30),
+		"items":   []interface{}{"a", "b"},
+		"headers": map[string]interface{}{"X-Token": "t"},
+	}, &c)
+	if err != nil {
+		t.Fatalf("DecodeConfig failed: %v", err)
+	}
+	if c.Order != "shuffle" || c.LookbackDays != 7 || c.Ratio != 0.5 {
+		t.Errorf("expected defaults to be applied, got %+v", c)
+	}
+	if c.Limit != 30 || c.Key != "doubao" || len(c.Items) != 2 || c.Headers["X-Token"] != "t" {
+		t.Errorf("unexpected decoded config: %+v", c)
+	}
+
+	invalid := map[string]struct {
+		raw  map[string]interface{}
+		want string
+	}{
+		"missing required": {map[string]interface{}{}, "key: required field is missing"},
+		"unknown key":      {map[string]interface{}{"key": "k", "limt": float64(1)}, "limt: unknown field"},
+		"string for int":   {map[string]interface{}{"key": "k", "limit": "30"}, `limit: expected integer, got string "30"`},
+		"fraction for int": {map[string]interface{}{"key": "k", "limit": 1.5}, "limit: expected integer, got 1.5"},
+		"out of range":     {map[string]interface{}{"key": "k", "limit": float64(101)}, "limit: value must be at most 100"},
+		"below min":        {map[string]interface{}{"key": "k", "lookback_days": float64(0)}, "lookback_days: value must be at least 1"},
+		"bad enum":         {map[string]interface{}{"key": "k", "order": "random"}, "order: must be one of [shuffle, desc, asc]"},
+		"bad list element": {map[string]interface{}{"key": "k", "items": []interface{}{"a", float64(1)}}, "items[1]: expected string"},
+	}
+	for name, tc := range invalid {
+		var c testNodeConfig
+		err := DecodeConfig(tc.raw, &c)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+
+	// 列表、映射和嵌套结构体中的 null 保留零值，嵌套结构体的字段仍使用默认值
+	var nulls struct {
+		Params map[string]interface{} `config:"params"`
+		Items  []string               `config:"items"`
+		Inner  struct {
+			Name string `config:"name" default:"x"`
+		} `config:"inner"`
+	}
+	err = DecodeConfig(map[string]interface{}{
+		"params": map[string]interface{}{"x": nil, "y": float64(1)},
+		"items":  []interface{}{"a", nil},
+		"inner":  map[string]interface{}{"name": nil},
+	}, &nulls)
+	if err != nil {
+		t.Fatalf("DecodeConfig with nulls failed: %v", err)
+	}
+	if v, ok := nulls.Params["x"]; !ok || v != nil || nulls.Params["y"] != float64(1) {
+		t.Errorf("unexpected params: %v", nulls.Params)
+	}
+	if len(nulls.Items) != 2 || nulls.Items[1] != "" || nulls.Inner.Name != "x" {
+		t.Errorf("unexpected decoded nulls: %+v", nulls)
+	}
+}
+
+func TestDescribeConfig(t *testing.T) {
+	fields := DescribeConfig(testNodeConfig{})
+	if len(fields) != 7 {
+		t.Fatalf("expected 7 fields, got %d", len(fields))
+	}
+	order, limit, key, headers := fields[0], fields[1], fields[4], fields[6]
+	if order.Key != "order" || order.Type != "string" || order.Default != "shuffle" || len(order.Enum) != 3 {
+		t.Errorf("unexpected order field: %+v", order)
+	}
+	if limit.Type != "integer" || limit.Min != "0" || limit.Max != "100" {
+		t.Errorf("unexpected limit field: %+v", limit)
+	}
+	if !key.Required {
+		t.Errorf("expected key to be required: %+v", key)
+	}
+	if headers.Type != "map<string>" || fields[5].Type != "list<string>" {
+		t.Errorf("unexpected collection types: %s, %s", headers.Type, fields[5].Type)
+	}
+}
+
+func TestConfigErrorPaths(t *testing.T) {
+	registry := newTestRegistry()
+	registry.Register("typed", func(cfg NodeConfig) (Node, error) {
+		var c struct {
+			LookbackDays int `config:"lookback_days" min:"1"`
+		}
+		if err := cfg.Decode(&c); err != nil {
+			return nil, err
+		}
+		return &stubNode{name: cfg.Name}, nil
+	})
+
+	cases := map[string]struct {
+		config string
+		want   string
+	}{
+		"top level": {
+			`{"pipelines": {"music": {"nodes": [
+				{"name": "a", "type": "stub"},
+				{"name": "b", "type": "typed", "config": {"lookback_days": "7"}}
+			]}}}`,
+			`pipelines.music.nodes[1].config.lookback_days: expected integer, got string "7"`,
+		},
+		"nested": {
+			`{"pipelines": {"music": {"nodes": [
+				{"name": "g", "type": "parallel", "nodes": [{"name": "b", "type": "typed", "config": {"days": 7}}]}
+			]}}}`,
+			"pipelines.music.nodes[0].nodes[0].config.days: unknown field",
+		},
+		"framework node": {
+			`{"pipelines": {"music": {"nodes": [
+				{"name": "g", "type": "parallel", "config": {"policy": "quorum:5"}, "nodes": [{"name": "a", "type": "stub"}]}
+			]}}}`,
+			"pipelines.music.nodes[0].config.policy: policy 'quorum' argument must be between 1 and 1",
+		},
+		"unknown type": {
+			`{"pipelines": {"music": {"nodes": [{"name": "a", "type": "nope"}]}}}`,
+			"pipelines.music.nodes[0]: unknown node type: nope",
+		},
+	}
+	for name, tc := range cases {
+		_, err := NewEngine(writeConfig(t, tc.config), registry)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestConfigErrorsCollectsAllProblems(t *testing.T) {
+	path := writeConfig(t, `{"pipelines": {
+		"music": {"nodes": [
+			{"name": "g", "type": "parallel", "nodes": [
+				{"name": "a", "type": "nope"},
+				{"name": "b", "type": "stub", "timeout_ms": -1}
+			]},
+			{"name": "c", "type": "pipeline"},
+			{"name": "e", "type": "typed", "config": {"lookback_days": 0, "days": 7}}
+		]},
+		"video": {"nodes": [{"name": "d", "type": "nope"}]}
+	}}`)
+
+	registry := newTestRegistry()
+	registry.Register("typed", func(cfg NodeConfig) (Node, error) {
+		var c struct {
+			LookbackDays int `config:"lookback_days" min:"1"`
+		}
+		if err := cfg.Decode(&c); err != nil {
+			return nil, err
+		}
+		return &stubNode{name: cfg.Name}, nil
+	})
+
+	_, err := NewEngine(path, registry)
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("expected ConfigErrors, got %T: %v", err, err)
+	}
+	want := []string{
+		"pipelines.music.nodes[0].nodes[0]: unknown node type: nope",
+		"pipelines.music.nodes[0].nodes[1].timeout_ms: must not be negative, got -1",
+		"pipelines.music.nodes[1].config.pipeline: required field is missing",
+		"pipelines.music.nodes[2].config.lookback_days: value must be at least 1, got 0",
+		"pipelines.music.nodes[2].config.days: unknown field",
+		"pipelines.video.nodes[0]: unknown node type: nope",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i := range want {
+		if errs[i].Error() != want[i] {
+			t.Errorf("error %d: expected %q, got %q", i, want[i], errs[i].Error())
+		}
+	}
+}
+
+func TestYAMLPipelineConfig(t *testing.T) {
+	t.Setenv("RE_LIMIT", "2")
+	path := filepath.Join(t.TempDir(), "pipelines.yaml")
+	content := `
+pipelines:
+  music:
+    timeout_ms: 1000
+    nodes:
+      - name: recall
+        type: stub
+        config:
+          items: [a, b, c]
+      - name: route
+        type: switch
+        cases:
+          - name: vip
+            when:
+              - {field: config.tier, op: eq, value: vip}
+            nodes:
+              - name: drop_a
+                type: drop
+                config: {items: [a]}
+      - name: typed
+        type: typed
+        retry: {max_attempts: 2}
+        config:
+          limit: ${RE_LIMIT}
+          order: ${RE_ORDER:-desc}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	registry := newTestRegistry()
+	var decoded struct {
+		Limit int    `config:"limit"`
+		Order string `config:"order"`
+	}
+	registry.Register("typed", func(cfg NodeConfig) (Node, error) {
+		if err := cfg.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return &stubNode{name: cfg.Name}, nil
+	})
+
+	engine, err := NewEngine(path, registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if decoded.Limit != 2 || decoded.Order != "desc" {
+		t.Errorf("expected env interpolated config, got %+v", decoded)
+	}
+
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	ctx.Config = map[string]interface{}{"tier": "vip"}
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := candidateNames(ctx); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("expected [b c], got %v", got)
+	}
+}
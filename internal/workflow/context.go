@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"recommend_engine/internal/logger"
@@ -14,68 +15,336 @@ type Context struct {
 	Ctx    context.Context
 	UserID string
 	User   *model.User
-	Config map[string]interface{}
+	Config map[string]interface{} // 请求级参数，执行期间只读；节点之间传递数据请使用 Set / Get
+	Debug  bool                   // debug 模式：记录每个节点执行后的候选集快照
 
-	// 数据流转区 (需要锁保护)
+	// 共享数据区 (需要锁保护)
+	// 通过 WithContext / Fork 派生出的 Context 共享同一份数据
+	*state
+
+	// 当前分支的候选集 (需要锁保护)
+	// 通过 Fork 派生出的 Context 拥有独立的候选集，用于 DAG 中的分支以及 parallel 的子节点
+	branch *candidateSet
+
+	// 本次请求使用的流程集合快照，供 pipeline 节点执行子流程
+	pipelines *pipelineSet
+
+	// 当前正在执行的节点对应的 Span
+	span *Span
+
+	// 当前节点上报的移除原因，仅在 debug 模式下存在
+	removals *removalLog
+
+	// parallel 子节点写入的召回结果、日志和快照先暂存在这里，子节点被采纳后才合并到上层
+	buffer *childBuffer
+
+	// 包裹每次节点执行的拦截器，由 Engine.Run 设置
+	interceptors []Interceptor
+
+	// 影子执行：结果会被丢弃，节点不应产生副作用 (如写入历史记录)
+	shadow bool
+}
+
+// state 是 Context 中各分支共享的数据流转区
+type state struct {
 	mu            sync.RWMutex
-	Candidates    []*model.Item             // 当前的主候选集
-	RecallResults map[string][]*model.Item  // 各路召回的原始结果 key: source_name
-	TraceLog      []string                  // 执行日志
+	RecallResults map[string][]*model.Item // 各路召回的原始结果 key: source_name
+	TraceLog      []string                 // 执行日志
+	trace         *Span                    // 结构化执行轨迹的根节点
+	snapshots     []Snapshot               // debug 模式下每个节点执行后的快照
+	experiments   map[string]string        // 本次请求命中的实验 -> 变体
+	values        map[valueKey]interface{} // 节点之间共享的带类型数据，通过 Set / Get 访问
+}
+
+// childBuffer 暂存 parallel 子节点写入共享数据区的内容
+// 按时返回的子节点由 parallel 合并到上层 (上层仍是子节点时合并到上层的缓冲区)，
+// 被策略放弃的子节点在后台结束后，其写入随缓冲区一起丢弃
+type childBuffer struct {
+	mu            sync.Mutex
+	recallResults map[string][]*model.Item
+	logs          []string
+	snapshots     []Snapshot
+	parent        *childBuffer
+}
+
+// candidateSet 是一个分支的候选集
+type candidateSet struct {
+	mu    sync.RWMutex
+	items []*model.Item
 }
 
 // NewContext 创建一个新的工作流上下文
 func NewContext(ctx context.Context, userID string, user *model.User) *Context {
 	return &Context{
-		Ctx:           ctx,
-		UserID:        userID,
-		User:          user,
-		RecallResults: make(map[string][]*model.Item),
-		Candidates:    make([]*model.Item, 0),
-		TraceLog:      make([]string, 0),
+		Ctx:    ctx,
+		UserID: userID,
+		User:   user,
+		state: &state{
+			RecallResults: make(map[string][]*model.Item),
+			TraceLog:      make([]string, 0),
+		},
+		branch: &candidateSet{
+			items: make([]*model.Item, 0),
+		},
 	}
 }
 
-// AddCandidates 向候选集中添加项目 (线程安全)
-func (c *Context) AddCandidates(items []*model.Item) {
+// WithContext 返回一个使用新 context.Context 的浅拷贝
+// 拷贝与原 Context 共享候选集、召回结果和日志，常用于为单个节点设置独立的超时
+func (c *Context) WithContext(ctx context.Context) *Context {
+	cp := *c
+	cp.Ctx = ctx
+	return &cp
+}
+
+// Fork 派生一个拥有独立候选集的分支 Context，初始候选集为 items 的副本
+// 分支与原 Context 共享召回结果和日志，但对候选集的修改互不影响
+func (c *Context) Fork(items []*model.Item) *Context {
+	cp := *c
+	branchItems := make([]*model.Item, len(items))
+	copy(branchItems, items)
+	cp.branch = &candidateSet{items: branchItems}
+	return &cp
+}
+
+// forkChild 派生 parallel 子节点使用的分支：拥有独立的候选集，写入共享数据区的内容暂存在缓冲区中
+func (c *Context) forkChild(items []*model.Item) *Context {
+	cp := c.Fork(items)
+	cp.buffer = &childBuffer{recallResults: make(map[string][]*model.Item), parent: c.buffer}
+	return cp
+}
+
+// adopt 将子节点分支的缓冲区合并到当前 Context
+func (c *Context) adopt(child *Context) {
+	b := child.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sources := make([]string, 0, len(b.recallResults))
+	for source := range b.recallResults {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		c.setRecallResult(source, b.recallResults[source])
+	}
+	for _, msg := range b.logs {
+		c.appendLog(msg)
+	}
+	for _, snap := range b.snapshots {
+		c.addSnapshot(snap)
+	}
+}
+
+// Clone 返回一个与原 Context 互不影响的副本，使用 ctx 作为新的 context.Context
+// 副本复制用户、请求配置和当前候选集，召回结果、日志、轨迹和快照从空开始，可以独立执行另一个场景
+func (c *Context) Clone(ctx context.Context) *Context {
+	var u *model.User
+	if c.User != nil {
+		userCopy := *c.User
+		userCopy.Favorites = append([]string(nil), c.User.Favorites...)
+		u = &userCopy
+	}
+	cp := NewContext(ctx, c.UserID, u)
+	if c.Config != nil {
+		cp.Config = make(map[string]interface{}, len(c.Config))
+		for k, v := range c.Config {
+			cp.Config[k] = v
+		}
+	}
+	cp.Debug = c.Debug
+	for _, item := range c.GetCandidates() {
+		itemCopy := *item
+		cp.branch.items = append(cp.branch.items, &itemCopy)
+	}
+	return cp
+}
+
+// IsShadow 判断当前是否为影子执行
+// 影子执行的结果只用于与主流程对比，有副作用的节点 (如写入外部存储) 应当在影子执行时跳过
+func (c *Context) IsShadow() bool {
+	return c.shadow
+}
+
+// withSpan 返回一个以 span 为当前 Span 的浅拷贝
+func (c *Context) withSpan(span *Span) *Context {
+	cp := *c
+	cp.span = span
+	return &cp
+}
+
+// Trace 返回结构化执行轨迹的根节点，Pipeline 尚未执行时返回 nil
+// 应当在 Engine.Run 返回之后读取
+func (c *Context) Trace() *Span {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.trace
+}
+
+// setTrace 设置执行轨迹的根节点
+func (c *Context) setTrace(span *Span) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trace = span
+}
+
+// Experiments 返回本次请求命中的实验及选中的变体 (实验名 -> 变体名)，没有命中实验时返回 nil
+// 应当在 Engine.Run 返回之后读取
+func (c *Context) Experiments() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.experiments) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(c.experiments))
+	for name, v := range c.experiments {
+		result[name] = v
+	}
+	return result
+}
+
+// setVariant 记录实验选中的变体
+func (c *Context) setVariant(experiment, variant string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.Candidates = append(c.Candidates, items...)
+	if c.experiments == nil {
+		c.experiments = make(map[string]string)
+	}
+	c.experiments[experiment] = variant
+}
+
+// AddCandidates 向候选集中添加项目 (线程安全)
+func (c *Context) AddCandidates(items []*model.Item) {
+	c.branch.mu.Lock()
+	defer c.branch.mu.Unlock()
+	c.branch.items = append(c.branch.items, items...)
 }
 
 // SetRecallResult 记录特定召回源的结果 (线程安全)
 func (c *Context) SetRecallResult(source string, items []*model.Item) {
+	c.setRecallResult(source, items)
+
+	if c.span != nil {
+		c.span.addRecall(source, len(items))
+	}
+
+	// 通常召回结果也会直接合并到 Candidates 中
+	c.AddCandidates(items)
+}
+
+// setRecallResult 写入召回结果，parallel 子节点中写入缓冲区
+func (c *Context) setRecallResult(source string, items []*model.Item) {
+	if b := c.buffer; b != nil {
+		b.mu.Lock()
+		b.recallResults[source] = items
+		b.mu.Unlock()
+		return
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.RecallResults[source] = items
-	// 通常召回结果也会直接合并到 Candidates 中
-	c.Candidates = append(c.Candidates, items...)
+	c.mu.Unlock()
+}
+
+// GetRecallResults 获取各路召回结果的副本 (线程安全)
+// parallel 子节点中同时包含自身及外层子节点尚未合并的结果
+func (c *Context) GetRecallResults() map[string][]*model.Item {
+	c.mu.RLock()
+	result := make(map[string][]*model.Item, len(c.RecallResults))
+	for source, items := range c.RecallResults {
+		result[source] = items
+	}
+	c.mu.RUnlock()
+
+	var chain []*childBuffer
+	for b := c.buffer; b != nil; b = b.parent {
+		chain = append(chain, b)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		chain[i].mu.Lock()
+		for source, items := range chain[i].recallResults {
+			result[source] = items
+		}
+		chain[i].mu.Unlock()
+	}
+	return result
 }
 
 // GetCandidates 获取当前候选集的副本 (线程安全)
 func (c *Context) GetCandidates() []*model.Item {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.branch.mu.RLock()
+	defer c.branch.mu.RUnlock()
 	// 返回副本以防止并发读写问题，或者由调用方保证后续只读
 	// 这里简单返回切片副本
-	result := make([]*model.Item, len(c.Candidates))
-	copy(result, c.Candidates)
+	result := make([]*model.Item, len(c.branch.items))
+	copy(result, c.branch.items)
 	return result
 }
 
+// CandidateCount 返回当前候选集的大小 (线程安全)
+func (c *Context) CandidateCount() int {
+	c.branch.mu.RLock()
+	defer c.branch.mu.RUnlock()
+	return len(c.branch.items)
+}
+
 // UpdateCandidates 更新整个候选集 (线程安全)
 // 通常用于过滤或排序阶段
 func (c *Context) UpdateCandidates(items []*model.Item) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.Candidates = items
+	c.branch.mu.Lock()
+	defer c.branch.mu.Unlock()
+	c.branch.items = items
+}
+
+// mergeBranch 将分支相对 base 的修改合并到当前候选集 (线程安全)
+// base 中不在分支候选集里的条目从当前候选集中移除，分支新增的条目追加到末尾；按条目指针比较
+func (c *Context) mergeBranch(base []*model.Item, branch *Context) {
+	remaining := make(map[*model.Item]int, len(base))
+	for _, item := range base {
+		remaining[item]++
+	}
+	var added []*model.Item
+	for _, item := range branch.GetCandidates() {
+		if remaining[item] > 0 {
+			remaining[item]--
+			continue
+		}
+		added = append(added, item)
+	}
+
+	c.branch.mu.Lock()
+	defer c.branch.mu.Unlock()
+	items := make([]*model.Item, 0, len(c.branch.items)+len(added))
+	for _, item := range c.branch.items {
+		if remaining[item] > 0 {
+			remaining[item]--
+			continue
+		}
+		items = append(items, item)
+	}
+	c.branch.items = append(items, added...)
 }
 
 // AddLog 添加追踪日志
 func (c *Context) AddLog(msg string) {
+	c.appendLog(msg)
+
+	if c.span != nil {
+		c.span.addLog(msg)
+	}
+	logger.Debug("[Workflow Trace] %s", msg)
+}
+
+// appendLog 写入执行日志，parallel 子节点中写入缓冲区
+func (c *Context) appendLog(msg string) {
+	if b := c.buffer; b != nil {
+		b.mu.Lock()
+		b.logs = append(b.logs, msg)
+		b.mu.Unlock()
+		return
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.TraceLog = append(c.TraceLog, msg)
-	logger.Debug("[Workflow Trace] %s", msg)
+	c.mu.Unlock()
 }
 
 // Node 定义工作流中的执行节点
@@ -1,29 +1,49 @@
 package workflow
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"recommend_engine/internal/config"
 )
 
+// DefaultPipelineTimeout 未配置 timeout_ms 时 Pipeline 的兜底超时时间
+const DefaultPipelineTimeout = 5 * time.Minute
+
 // PipelineConfig 单个 Pipeline 的配置
 type PipelineConfig struct {
-	Description string       `json:"description"`
-	TimeoutMs   int          `json:"timeout_ms"`
-	Nodes       []NodeConfig `json:"nodes"`
+	Description string       `json:"description" yaml:"description"`
+	TimeoutMs   int          `json:"timeout_ms" yaml:"timeout_ms"`
+	Nodes       []NodeConfig `json:"nodes" yaml:"nodes"`
+
+	// Experiment 场景级 A/B 实验，声明后按用户选择变体执行，不能与 nodes 同时使用
+	Experiment *ExperimentConfig `json:"experiment,omitempty" yaml:"experiment,omitempty"`
+
+	// Shadow 影子流程，抽样请求在后台执行另一个场景并与本场景的结果对比
+	Shadow *ShadowConfig `json:"shadow,omitempty" yaml:"shadow,omitempty"`
 }
 
 // NodeConfig 节点的配置片段
 type NodeConfig struct {
-	Name   string                 `json:"name"`
-	Type   string                 `json:"type"`
-	Config map[string]interface{} `json:"config"`
-	Nodes  []NodeConfig           `json:"nodes,omitempty"` // 用于组合节点 (如 parallel)
+	Name      string                 `json:"name" yaml:"name"`
+	Type      string                 `json:"type" yaml:"type"`
+	TimeoutMs int                    `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"` // 节点级超时，0 表示不单独限制
+	DependsOn []string               `json:"depends_on,omitempty" yaml:"depends_on,omitempty"` // 依赖的节点名，声明后 Pipeline 按 DAG 调度
+	Retry     *RetryConfig           `json:"retry,omitempty" yaml:"retry,omitempty"`           // 节点级重试，为空表示不重试
+	Config    map[string]interface{} `json:"config" yaml:"config"`
+	Nodes     []NodeConfig           `json:"nodes,omitempty" yaml:"nodes,omitempty"`     // 用于组合节点 (如 parallel)
+	Cases     []CaseConfig           `json:"cases,omitempty" yaml:"cases,omitempty"`     // 用于 switch 节点的条件分支
+	Default   []NodeConfig           `json:"default,omitempty" yaml:"default,omitempty"` // 用于 switch 节点，没有分支命中时执行
 }
 
 // GlobalConfig 整个配置文件的结构
 type GlobalConfig struct {
-	Pipelines map[string]PipelineConfig `json:"pipelines"`
+	Pipelines     map[string]PipelineConfig `json:"pipelines" yaml:"pipelines"`
+	InitTimeoutMs int                       `json:"init_timeout_ms,omitempty" yaml:"init_timeout_ms,omitempty"` // 所有节点 Init 的总超时，0 表示使用 DefaultInitTimeout
 }
 
 // NodeFactory 创建 Node 的函数签名
@@ -46,18 +66,89 @@ func (r *Registry) Register(nodeType string, factory NodeFactory) {
 }
 
 // CreateNode 根据配置创建节点实例
+// 返回的错误为 *ConfigError，路径相对于该节点 (如 config.limit、nodes[0].config.count)
 func (r *Registry) CreateNode(cfg NodeConfig) (Node, error) {
-	// 特殊处理 parallel 节点，因为它属于框架层面的能力
-	if cfg.Type == "parallel" {
-		var children []Node
-		for _, childCfg := range cfg.Nodes {
-			childNode, err := r.CreateNode(childCfg)
-			if err != nil {
-				return nil, err
-			}
-			children = append(children, childNode)
+	node, err := r.createNode(cfg)
+	if err != nil {
+		return nil, withPath("", err)
+	}
+
+	// 节点级超时：包装一层，让该节点拥有独立的 deadline
+	if cfg.TimeoutMs < 0 {
+		return nil, withPath("timeout_ms", fmt.Errorf("must not be negative, got %d", cfg.TimeoutMs))
+	}
+	if cfg.TimeoutMs > 0 {
+		node = NewTimeoutNode(node, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	}
+
+	// 重试包装在超时之外，每次尝试都拥有独立的超时时间
+	// 组合节点的子节点可能在整体失败前已经写入候选集或召回结果，重试会在这些部分结果上再次执行
+	if cfg.Retry != nil {
+		if compositeTypes[cfg.Type] {
+			return nil, withPath("retry", fmt.Errorf("retry is not supported on composite node type '%s', configure it on the child nodes instead", cfg.Type))
 		}
-		return NewParallelNode(cfg.Name, children), nil
+		retryNode, err := NewRetryNode(node, *cfg.Retry)
+		if err != nil {
+			return nil, withPath("retry", err)
+		}
+		node = retryNode
+	}
+	return node, nil
+}
+
+// compositeTypes 是由引擎内置、包含子节点的节点类型
+var compositeTypes = map[string]bool{"parallel": true, "fallback": true, "pipeline": true, "switch": true}
+
+// parallelConfig 是 parallel 节点的 config
+type parallelConfig struct {
+	Policy string `config:"policy"`
+}
+
+// subPipelineConfig 是 pipeline 节点的 config
+type subPipelineConfig struct {
+	Pipeline string `config:"pipeline" required:"true" min:"1"`
+}
+
+func (r *Registry) createNode(cfg NodeConfig) (Node, error) {
+	// 特殊处理 parallel / fallback / pipeline / switch 节点，因为它们属于框架层面的能力
+	switch cfg.Type {
+	case "parallel":
+		var c parallelConfig
+		if err := cfg.Decode(&c); err != nil {
+			return nil, err
+		}
+		children, err := r.createChildren("nodes", cfg.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := ParseParallelPolicy(c.Policy, len(children))
+		if err != nil {
+			return nil, withPath("config.policy", err)
+		}
+		return NewParallelNode(cfg.Name, children, policy), nil
+	case "fallback":
+		if err := cfg.Decode(&struct{}{}); err != nil {
+			return nil, err
+		}
+		children, err := r.createChildren("nodes", cfg.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			return nil, fmt.Errorf("fallback node '%s' has no children", cfg.Name)
+		}
+		return NewFallbackNode(cfg.Name, children), nil
+	case "pipeline":
+		var c subPipelineConfig
+		if err := cfg.Decode(&c); err != nil {
+			return nil, err
+		}
+		return NewSubPipelineNode(cfg.Name, c.Pipeline), nil
+	case "switch":
+		if err := cfg.Decode(&struct{}{}); err != nil {
+			return nil, err
+		}
+		return r.createSwitch(cfg)
 	}
 
 	factory, ok := r.factories[cfg.Type]
@@ -67,61 +158,378 @@ func (r *Registry) CreateNode(cfg NodeConfig) (Node, error) {
 	return factory(cfg)
 }
 
+// createChildren 创建组合节点的子节点，field 为子节点列表在配置中的路径
+func (r *Registry) createChildren(field string, cfgs []NodeConfig) ([]Node, error) {
+	var children []Node
+	var errs ConfigErrors
+	for i, childCfg := range cfgs {
+		path := fmt.Sprintf("%s[%d]", field, i)
+		if len(childCfg.DependsOn) > 0 {
+			errs = errs.append(withPath(path+".depends_on", fmt.Errorf("depends_on is only supported at pipeline level")))
+			continue
+		}
+		childNode, err := r.CreateNode(childCfg)
+		if err != nil {
+			errs = errs.append(withPath(path, err))
+			continue
+		}
+		children = append(children, childNode)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return children, nil
+}
+
+// createSwitch 创建 switch 节点，条件在加载时校验
+func (r *Registry) createSwitch(cfg NodeConfig) (Node, error) {
+	if len(cfg.Cases) == 0 {
+		return nil, fmt.Errorf("switch node '%s' has no cases", cfg.Name)
+	}
+
+	var cases []switchCase
+	for i, caseCfg := range cfg.Cases {
+		path := fmt.Sprintf("cases[%d]", i)
+		name := caseCfg.Name
+		if name == "" {
+			name = fmt.Sprintf("case_%d", i)
+		}
+		if len(caseCfg.When) == 0 {
+			return nil, withPath(path, fmt.Errorf("case '%s' has no conditions", name))
+		}
+		for j, cond := range caseCfg.When {
+			if err := cond.validate(); err != nil {
+				return nil, withPath(fmt.Sprintf("%s.when[%d]", path, j), err)
+			}
+		}
+		nodes, err := r.createChildren(path+".nodes", caseCfg.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, switchCase{name: name, when: caseCfg.When, nodes: nodes})
+	}
+
+	defaultCase, err := r.createChildren("default", cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+	return NewSwitchNode(cfg.Name, cases, defaultCase), nil
+}
+
+// pipeline 是加载后的单个场景流程
+type pipeline struct {
+	nodes      []Node
+	experiment *experiment // 不为空时按用户选择变体，nodes 为空
+	shadow     *shadow     // 不为空时抽样执行影子流程
+	timeout    time.Duration
+}
+
+// pipelineSet 是一次完整加载得到的所有场景流程
+// 热更新时整体替换，保证单个请求始终运行在同一份配置上
+type pipelineSet struct {
+	pipelines map[string]*pipeline // scene -> pipeline
+	config    GlobalConfig         // 构建这些 Pipeline 的原始配置
+	warnings  []error              // 加载时发现的不影响使用的问题，如节点顺序可疑
+
+	warmupMu sync.Mutex
+	warmups  []*WarmupStat // 各节点 Init 的执行情况
+
+	// 引用计数：被替换后等待使用它的请求全部结束，再关闭其中的节点
+	refMu     sync.Mutex
+	refs      int
+	retired   bool
+	stopped   bool // Engine.Close 之后不再接受新的请求 (包括影子流程)
+	closed    bool
+	closeOnce sync.Once
+	done      chan struct{} // 所有节点 Close 完成后关闭
+}
+
 // Engine 流程引擎
 type Engine struct {
-	pipelines map[string][]Node // scene -> nodes
-	registry  *Registry
+	configPath string
+	registry   *Registry
+	current    atomic.Value // *pipelineSet
+
+	interceptors []Interceptor // 包裹每次节点执行的拦截器，第一个位于最外层
+
+	tracesMu   sync.Mutex
+	lastTraces map[string]*Span // scene -> 最近一次执行的轨迹
+
+	shadowMu    sync.Mutex
+	shadowStats map[string]*shadowCounter // scene -> 影子流程的累计统计
+
+	reloadMu sync.Mutex   // 串行化 Reload
+	modTime  time.Time    // 最近一次成功加载时配置文件的修改时间
+	closed   int32        // 已调用 Close 时为 1；原子读取，修改时同时持有 reloadMu，使 Readiness 不必等待进行中的 Reload
+	loading  atomic.Value // *pipelineSet，正在执行 Init 的新配置
 }
 
 // NewEngine 创建引擎并加载配置
 func NewEngine(configPath string, registry *Registry) (*Engine, error) {
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read pipeline config: %w", err)
+	engine := &Engine{
+		configPath:   configPath,
+		registry:     registry,
+		interceptors: DefaultInterceptors(),
+		lastTraces:   make(map[string]*Span),
+		shadowStats:  make(map[string]*shadowCounter),
+	}
+	if err := engine.Reload(); err != nil {
+		return nil, err
 	}
+	return engine, nil
+}
 
+// LoadGlobalConfig 读取并解析 Pipeline 配置文件，不构建节点
+// 按扩展名支持 YAML (.yaml / .yml) 和 JSON (.json)，并展开 ${ENV_VAR} 形式的环境变量引用
+func LoadGlobalConfig(configPath string) (GlobalConfig, error) {
 	var globalCfg GlobalConfig
-	if err := json.Unmarshal(data, &globalCfg); err != nil {
-		return nil, fmt.Errorf("failed to parse pipeline config: %w", err)
+	if err := config.Load(configPath, &globalCfg); err != nil {
+		return globalCfg, fmt.Errorf("failed to load pipeline config: %w", err)
 	}
+	return globalCfg, nil
+}
 
-	engine := &Engine{
-		pipelines: make(map[string][]Node),
-		registry:  registry,
+// ValidateConfig 读取配置文件并构建所有场景的节点，返回加载时的告警
+// 与 NewEngine 报告相同的配置错误，但不调用节点的 Init：不会启动外部进程、访问依赖的服务，
+// 也不会因依赖暂时不可用而失败。构建出的节点随后被关闭 (Closer)。
+func ValidateConfig(configPath string, registry *Registry) ([]error, error) {
+	set, err := loadPipelines(configPath, registry)
+	if err != nil {
+		return nil, err
+	}
+	set.close()
+	return set.warnings, nil
+}
+
+// loadPipelines 读取配置文件并通过 Registry 构建所有场景的节点
+func loadPipelines(configPath string, registry *Registry) (*pipelineSet, error) {
+	globalCfg, err := LoadGlobalConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if globalCfg.InitTimeoutMs < 0 {
+		return nil, withPath("init_timeout_ms", fmt.Errorf("must not be negative, got %d", globalCfg.InitTimeoutMs))
+	}
+
+	// 子流程引用在构建节点前检查，保证引用的 Pipeline 存在且没有递归
+	if err := checkPipelineRefs(globalCfg); err != nil {
+		return nil, err
 	}
 
-	for scene, pipeCfg := range globalCfg.Pipelines {
-		var nodes []Node
-		for _, nodeCfg := range pipeCfg.Nodes {
-			node, err := registry.CreateNode(nodeCfg)
+	set := &pipelineSet{
+		pipelines: make(map[string]*pipeline),
+		config:    globalCfg,
+		done:      make(chan struct{}),
+	}
+
+	// 各 Pipeline 独立构建，一次报告所有 Pipeline 中的错误
+	scenes := make([]string, 0, len(globalCfg.Pipelines))
+	for scene := range globalCfg.Pipelines {
+		scenes = append(scenes, scene)
+	}
+	sort.Strings(scenes)
+
+	var errs ConfigErrors
+	for _, scene := range scenes {
+		pipeCfg := globalCfg.Pipelines[scene]
+		if pipeCfg.TimeoutMs < 0 {
+			errs = append(errs, withPath("pipelines."+scene+".timeout_ms", fmt.Errorf("must not be negative, got %d", pipeCfg.TimeoutMs)))
+			continue
+		}
+		timeout := DefaultPipelineTimeout
+		if pipeCfg.TimeoutMs > 0 {
+			timeout = time.Duration(pipeCfg.TimeoutMs) * time.Millisecond
+		}
+
+		p := &pipeline{timeout: timeout}
+		if pipeCfg.Shadow != nil {
+			sh, err := buildShadow(scene, pipeCfg.Shadow, globalCfg.Pipelines)
 			if err != nil {
-				return nil, fmt.Errorf("failed to create node '%s' in pipeline '%s': %w", nodeCfg.Name, scene, err)
+				errs = errs.append(withPath("pipelines."+scene+".shadow", err))
+				continue
 			}
-			nodes = append(nodes, node)
+			p.shadow = sh
 		}
-		engine.pipelines[scene] = nodes
+
+		if pipeCfg.Experiment != nil {
+			if len(pipeCfg.Nodes) > 0 {
+				errs = append(errs, withPath("pipelines."+scene+".nodes", fmt.Errorf("nodes cannot be combined with experiment, declare them in each variant")))
+				continue
+			}
+			exp, err := buildExperiment(scene, pipeCfg.Experiment, registry)
+			if err != nil {
+				errs = errs.append(withPath("pipelines."+scene+".experiment", err))
+				continue
+			}
+			p.experiment = exp
+			set.pipelines[scene] = p
+			continue
+		}
+
+		nodes, err := buildNodes(scene, pipeCfg.Nodes, registry)
+		if err != nil {
+			errs = errs.append(withPath("pipelines."+scene, err))
+			continue
+		}
+		p.nodes = nodes
+		set.pipelines[scene] = p
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	return engine, nil
+	// 所有节点构建成功后，按执行顺序检查各阶段的读写依赖
+	stageErrs, warnings := checkStages(set)
+	if len(stageErrs) > 0 {
+		return nil, stageErrs
+	}
+	set.warnings = warnings
+
+	return set, nil
+}
+
+// buildNodes 构建单个 Pipeline 的节点
+// 只要有节点声明了 depends_on，整个 Pipeline 就按 DAG 调度，否则按声明顺序串行执行
+func buildNodes(scene string, cfgs []NodeConfig, registry *Registry) ([]Node, error) {
+	for _, nodeCfg := range cfgs {
+		if len(nodeCfg.DependsOn) > 0 {
+			dag, err := NewDAGNode(scene, cfgs, registry)
+			if err != nil {
+				return nil, err
+			}
+			return []Node{dag}, nil
+		}
+	}
+
+	var nodes []Node
+	var errs ConfigErrors
+	for i, nodeCfg := range cfgs {
+		node, err := registry.CreateNode(nodeCfg)
+		if err != nil {
+			errs = errs.append(withPath(fmt.Sprintf("nodes[%d]", i), err))
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return nodes, nil
+}
+
+// pipelines 返回当前生效的流程集合
+func (e *Engine) pipelines() *pipelineSet {
+	return e.current.Load().(*pipelineSet)
+}
+
+// Use 追加拦截器，追加的拦截器位于默认拦截器 (panic 恢复、耗时统计) 之内
+// 应当在开始处理请求之前调用
+func (e *Engine) Use(interceptors ...Interceptor) {
+	e.interceptors = append(e.interceptors, interceptors...)
 }
 
 // Run 执行指定场景的推荐流程
 func (e *Engine) Run(ctx *Context, scene string) error {
-	nodes, ok := e.pipelines[scene]
+	// 在开始时获取快照，执行过程中发生的热更新不会影响本次请求
+	set, err := e.acquirePipelines()
+	if err != nil {
+		return err
+	}
+	defer set.release()
+
+	// 影子流程使用主流程开始前克隆的 Context，保证两者的输入相同
+	var sh *shadow
+	var shadowCtx *Context
+	if p, ok := set.pipelines[scene]; ok && p.shadow != nil && !ctx.shadow && p.shadow.sample() {
+		sh = p.shadow
+		shadowCtx = ctx.shadowContext()
+	}
+
+	wfCtx := *ctx
+	wfCtx.pipelines = set
+	wfCtx.interceptors = e.interceptors
+	start := time.Now()
+	err = set.run(&wfCtx, scene)
+	latency := time.Since(start)
+
+	if trace := wfCtx.Trace(); trace != nil {
+		e.tracesMu.Lock()
+		e.lastTraces[scene] = trace
+		e.tracesMu.Unlock()
+	}
+
+	// 影子流程在主流程结束后于后台执行，不影响本次请求的耗时
+	if sh != nil {
+		e.runShadow(set, scene, sh, shadowCtx, wfCtx.GetCandidates(), latency, err)
+	}
+	return err
+}
+
+// PipelineConfig 返回当前生效的指定场景的配置
+func (e *Engine) PipelineConfig(scene string) (PipelineConfig, bool) {
+	cfg, ok := e.pipelines().config.Pipelines[scene]
+	return cfg, ok
+}
+
+// Warnings 返回当前生效的配置在加载时发现的警告 (如 recall 节点位于截断候选集的排序之后)
+// 警告不会阻止配置加载
+func (e *Engine) Warnings() []error {
+	return e.pipelines().warnings
+}
+
+// LastTrace 返回指定场景最近一次执行的轨迹，尚未执行过时返回 nil
+func (e *Engine) LastTrace(scene string) *Span {
+	e.tracesMu.Lock()
+	defer e.tracesMu.Unlock()
+	return e.lastTraces[scene]
+}
+
+// run 执行流程集合中的指定 Pipeline，也被 pipeline 类型的节点用于执行子流程
+// 整个流程受 Pipeline 的 timeout_ms 约束，超时后 ctx.Ctx 会被取消
+func (s *pipelineSet) run(ctx *Context, scene string) (err error) {
+	p, ok := s.pipelines[scene]
 	if !ok {
 		return fmt.Errorf("pipeline not found for scene: %s", scene)
 	}
 
-	ctx.AddLog(fmt.Sprintf("Starting pipeline execution for scene: %s", scene))
+	runCtx, cancel := context.WithTimeout(ctx.Ctx, p.timeout)
+	defer cancel()
+	wfCtx := ctx.WithContext(runCtx)
+
+	// 顶层流程创建轨迹的根节点；子流程挂在 pipeline 节点的 Span 之下
+	if wfCtx.span == nil {
+		root := newSpan(scene, "pipeline", wfCtx.CandidateCount())
+		wfCtx.setTrace(root)
+		wfCtx = wfCtx.withSpan(root)
+		defer func() { root.finish(wfCtx.CandidateCount(), err) }()
+	}
+
+	wfCtx.AddLog(fmt.Sprintf("Starting pipeline execution for scene: %s (timeout: %v)", scene, p.timeout))
+
+	// 实验场景：按用户选择变体，记录到 Context 和当前 Span (顶层流程为根 Span，子流程为 pipeline 节点的 Span)
+	nodes := p.nodes
+	if p.experiment != nil {
+		v := p.experiment.assign(wfCtx.UserID)
+		nodes = v.nodes
+		wfCtx.setVariant(p.experiment.name, v.name)
+		wfCtx.span.SetAttribute("experiment", p.experiment.name)
+		wfCtx.span.SetAttribute("variant", v.name)
+		wfCtx.AddLog(fmt.Sprintf("Experiment %s: user %s assigned to variant %s", p.experiment.name, wfCtx.UserID, v.name))
+	}
 
-	for _, node := range nodes {
-		ctx.AddLog(fmt.Sprintf("Executing node: %s (%s)", node.Name(), node.Type()))
-		if err := node.Execute(ctx); err != nil {
-			ctx.AddLog(fmt.Sprintf("Node execution failed: %v", err))
+	for i, node := range nodes {
+		wfCtx.AddLog(fmt.Sprintf("Executing node: %s (%s)", node.Name(), node.Type()))
+		if err = runNode(wfCtx, node); err != nil {
+			if IsCanceled(err) {
+				wfCtx.AddLog(fmt.Sprintf("Pipeline canceled: %v, skipping %d remaining node(s)", err, len(nodes)-i-1))
+			} else {
+				wfCtx.AddLog(fmt.Sprintf("Node execution failed: %v", err))
+			}
 			return err
 		}
 	}
 
-	ctx.AddLog("Pipeline execution completed")
+	wfCtx.AddLog("Pipeline execution completed")
 	return nil
 }
@@ -0,0 +1,669 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+)
+
+// stubNode 是测试用的节点，执行时可选地阻塞直到 ctx 被取消
+type stubNode struct {
+	name  string
+	items []string
+	block bool
+	err   error
+}
+
+func (n *stubNode) Name() string { return n.name }
+func (n *stubNode) Type() string { return "recall" }
+
+func (n *stubNode) Execute(ctx *Context) error {
+	if n.block {
+		<-ctx.Ctx.Done()
+		return ctx.Ctx.Err()
+	}
+	if n.err != nil {
+		return n.err
+	}
+	var items []*model.Item
+	for _, name := range n.items {
+		items = append(items, &model.Item{ID: name, Name: name, Source: n.name})
+	}
+	ctx.SetRecallResult(n.name, items)
+	return nil
+}
+
+// newTestRegistry 注册 stub 节点类型，config.items 为返回的条目，config.block 表示阻塞
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("stub", func(cfg NodeConfig) (Node, error) {
+		n := &stubNode{name: cfg.Name}
+		if items, ok := cfg.Config["items"].([]interface{}); ok {
+			for _, it := range items {
+				n.items = append(n.items, it.(string))
+			}
+		}
+		n.block, _ = cfg.Config["block"].(bool)
+		if msg, ok := cfg.Config["error"].(string); ok {
+			n.err = errors.New(msg)
+		}
+		return n, nil
+	})
+	r.Register("drop", func(cfg NodeConfig) (Node, error) {
+		n := &dropNode{name: cfg.Name, drop: make(map[string]bool)}
+		if items, ok := cfg.Config["items"].([]interface{}); ok {
+			for _, it := range items {
+				n.drop[it.(string)] = true
+			}
+		}
+		return n, nil
+	})
+	return r
+}
+
+// dropNode 是测试用的过滤节点，移除指定名称的条目
+type dropNode struct {
+	name string
+	drop map[string]bool
+}
+
+func (n *dropNode) Name() string { return n.name }
+func (n *dropNode) Type() string { return "filter" }
+
+func (n *dropNode) Execute(ctx *Context) error {
+	var kept []*model.Item
+	for _, item := range ctx.GetCandidates() {
+		if !n.drop[item.Name] {
+			kept = append(kept, item)
+		}
+	}
+	ctx.UpdateCandidates(kept)
+	return nil
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pipelines.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func candidateNames(ctx *Context) []string {
+	var names []string
+	for _, item := range ctx.GetCandidates() {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"slow": {
+				"timeout_ms": 50,
+				"nodes": [{"name": "hang", "type": "stub", "config": {"block": true}}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	start := time.Now()
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	err = engine.Run(ctx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("pipeline timeout not enforced, took %v", elapsed)
+	}
+}
+
+func TestNodeTimeoutInsideParallel(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"timeout_ms": 5000,
+				"nodes": [{
+					"name": "group",
+					"type": "parallel",
+					"nodes": [
+						{"name": "fast", "type": "stub", "config": {"items": ["a", "b"]}},
+						{"name": "hang", "type": "stub", "timeout_ms": 30, "config": {"block": true}}
+					]
+				}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	start := time.Now()
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("expected partial success, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("node timeout not enforced, took %v", elapsed)
+	}
+	if got := candidateNames(ctx); len(got) != 2 {
+		t.Errorf("expected 2 candidates from fast node, got %v", got)
+	}
+}
+
+func TestReloadKeepsOldPipelinesOnError(t *testing.T) {
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "stub", "config": {"items": ["x"]}}]}}}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// 写入一份包含未知节点类型的配置，Reload 应失败且旧配置继续生效
+	bad := `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "missing"}]}}}`
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := engine.Reload(); err == nil {
+		t.Fatal("expected reload error for unknown node type")
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("old pipeline should still run: %v", err)
+	}
+
+	good := `{"pipelines": {"movie": {"nodes": [{"name": "b", "type": "stub", "config": {"items": ["y"]}}]}}}`
+	if err := os.WriteFile(path, []byte(good), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := engine.Reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	ctx = NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err == nil {
+		t.Error("expected music pipeline to be gone after reload")
+	}
+	ctx = NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "movie"); err != nil {
+		t.Errorf("movie pipeline should run after reload: %v", err)
+	}
+}
+
+func TestDAGFiltersSingleBranch(t *testing.T) {
+	// recall A 和 B，只对 A 做过滤，然后汇合
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"nodes": [
+					{"name": "recall_a", "type": "stub", "config": {"items": ["a1", "a2", "shared"]}},
+					{"name": "recall_b", "type": "stub", "config": {"items": ["b1", "shared"]}},
+					{"name": "filter_a", "type": "drop", "depends_on": ["recall_a"], "config": {"items": ["shared"]}},
+					{"name": "merge", "type": "drop", "depends_on": ["filter_a", "recall_b"], "config": {"items": ["a2"]}}
+				]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	got := candidateNames(ctx)
+	want := []string{"a1", "b1", "shared"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+			break
+		}
+	}
+	if len(ctx.GetRecallResults()) != 2 {
+		t.Errorf("expected recall results from both sources, got %v", ctx.GetRecallResults())
+	}
+}
+
+func TestDAGLoadErrors(t *testing.T) {
+	cases := map[string]string{
+		"cycle": `{"pipelines": {"music": {"nodes": [
+			{"name": "a", "type": "stub", "depends_on": ["c"]},
+			{"name": "b", "type": "stub", "depends_on": ["a"]},
+			{"name": "c", "type": "stub", "depends_on": ["b"]}
+		]}}}`,
+		"missing": `{"pipelines": {"music": {"nodes": [
+			{"name": "a", "type": "stub"},
+			{"name": "b", "type": "stub", "depends_on": ["nope"]}
+		]}}}`,
+		"duplicate": `{"pipelines": {"music": {"nodes": [
+			{"name": "a", "type": "stub"},
+			{"name": "a", "type": "stub", "depends_on": ["a"]}
+		]}}}`,
+	}
+	for name, content := range cases {
+		if _, err := NewEngine(writeConfig(t, content), newTestRegistry()); err == nil {
+			t.Errorf("%s: expected load error", name)
+		}
+	}
+}
+
+// flakyNode 前 failures 次执行失败 (返回 err，默认为普通错误)，之后成功
+type flakyNode struct {
+	stubNode
+	failures int
+	calls    int
+	err      error
+}
+
+func (n *flakyNode) Execute(ctx *Context) error {
+	n.calls++
+	if n.calls <= n.failures {
+		if n.err != nil {
+			return n.err
+		}
+		return errors.New("temporary failure")
+	}
+	return n.stubNode.Execute(ctx)
+}
+
+func TestRetryNode(t *testing.T) {
+	flaky := &flakyNode{stubNode: stubNode{name: "flaky", items: []string{"a"}}, failures: 2}
+	node, err := NewRetryNode(flaky, RetryConfig{MaxAttempts: 3, BackoffMs: 1})
+	if err != nil {
+		t.Fatalf("NewRetryNode failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := node.Execute(ctx); err != nil {
+		t.Fatalf("expected success on third attempt, got %v", err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", flaky.calls)
+	}
+
+	// retry_on 按错误类别而不是错误信息匹配
+	retryOn := []string{RetryOnTimeout, RetryOnServerError}
+	cases := map[string]struct {
+		err   error
+		calls int
+	}{
+		"plain error":          {errors.New("temporary failure"), 1},
+		"timeout in message":   {errors.New("upstream said: timeout while loading"), 1},
+		"node timeout":         {&nodeTimeoutError{node: "flaky", timeout: time.Millisecond, err: errors.New("slow")}, 3},
+		"wrapped deadline":     {fmt.Errorf("llm chat failed: %w", context.DeadlineExceeded), 3},
+		"server error":         {fmt.Errorf("llm chat failed: %w", &testStatusError{503}), 3},
+		"client error":         {&testStatusError{400}, 1},
+		"rate limited not set": {&testStatusError{429}, 1},
+	}
+	for name, tc := range cases {
+		flaky := &flakyNode{stubNode: stubNode{name: "flaky"}, failures: 2, err: tc.err}
+		node, err := NewRetryNode(flaky, RetryConfig{MaxAttempts: 3, RetryOn: retryOn})
+		if err != nil {
+			t.Fatalf("NewRetryNode failed: %v", err)
+		}
+		node.Execute(ctx)
+		if flaky.calls != tc.calls {
+			t.Errorf("%s: expected %d calls, got %d", name, tc.calls, flaky.calls)
+		}
+	}
+
+	if _, err := NewRetryNode(flaky, RetryConfig{MaxAttempts: 3, RetryOn: []string{"status 5"}}); err == nil || !strings.Contains(err.Error(), "unknown error kind 'status 5'") {
+		t.Errorf("expected unknown error kind, got %v", err)
+	}
+
+	// 组合节点不能配置重试
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [
+		{"name": "g", "type": "parallel", "retry": {"max_attempts": 2}, "nodes": [{"name": "a", "type": "stub"}]}
+	]}}}`)
+	if _, err := NewEngine(path, newTestRegistry()); err == nil || !strings.Contains(err.Error(), "pipelines.music.nodes[0].retry: retry is not supported on composite node type 'parallel'") {
+		t.Errorf("expected composite retry error, got %v", err)
+	}
+}
+
+type testStatusError struct{ status int }
+
+func (e *testStatusError) Error() string   { return fmt.Sprintf("status %d", e.status) }
+func (e *testStatusError) HTTPStatus() int { return e.status }
+
+func TestFallbackNode(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"nodes": [{
+					"name": "recall",
+					"type": "fallback",
+					"nodes": [
+						{"name": "doubao", "type": "stub", "config": {"error": "doubao down"}},
+						{"name": "xinhuo", "type": "stub", "timeout_ms": 20, "config": {"block": true}},
+						{"name": "popular", "type": "stub", "config": {"items": ["hit1", "hit2"]}}
+					]
+				}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	got := candidateNames(ctx)
+	if len(got) != 2 || got[0] != "hit1" {
+		t.Errorf("expected static fallback results, got %v", got)
+	}
+}
+
+func TestSwitchNode(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"nodes": [{
+					"name": "entry",
+					"type": "switch",
+					"cases": [
+						{"name": "cold_start", "when": [{"field": "user.favorites_count", "op": "eq", "value": 0}],
+						 "nodes": [{"name": "popular", "type": "stub", "config": {"items": ["hit"]}}]},
+						{"name": "vip", "when": [{"field": "config.tier", "op": "in", "value": ["gold", "vip"]}],
+						 "nodes": [{"name": "vip_recall", "type": "stub", "config": {"items": ["v1", "v2"]}}]}
+					],
+					"default": [{"name": "llm", "type": "stub", "config": {"items": ["l1", "l2", "l3"]}}]
+				}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	run := func(favorites []string, cfg map[string]interface{}) int {
+		ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1", Favorites: favorites})
+		ctx.Config = cfg
+		if err := engine.Run(ctx, "music"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return len(ctx.GetCandidates())
+	}
+
+	if got := run(nil, nil); got != 1 {
+		t.Errorf("cold start: expected 1 candidate, got %d", got)
+	}
+	if got := run([]string{"song"}, map[string]interface{}{"tier": "vip"}); got != 2 {
+		t.Errorf("vip: expected 2 candidates, got %d", got)
+	}
+	if got := run([]string{"song"}, map[string]interface{}{"tier": "free"}); got != 3 {
+		t.Errorf("default: expected 3 candidates, got %d", got)
+	}
+
+	bad := `{"pipelines": {"music": {"nodes": [{"name": "s", "type": "switch",
+		"cases": [{"when": [{"field": "user.age", "op": "gt", "value": 1}], "nodes": []}]}]}}}`
+	if _, err := NewEngine(writeConfig(t, bad), newTestRegistry()); err == nil {
+		t.Error("expected load error for unknown condition field")
+	}
+}
+
+func TestSubPipelineNode(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"common_tail": {
+				"nodes": [{"name": "dedup", "type": "drop", "config": {"items": ["seen"]}}]
+			},
+			"music": {
+				"nodes": [
+					{"name": "recall", "type": "stub", "config": {"items": ["a", "seen"]}},
+					{"name": "tail", "type": "pipeline", "config": {"pipeline": "common_tail"}}
+				]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := candidateNames(ctx); len(got) != 1 || got[0] != "a" {
+		t.Errorf("expected [a], got %v", got)
+	}
+
+	cases := map[string]struct {
+		config string
+		want   string
+	}{
+		"recursive": {`{"pipelines": {
+			"b": {"nodes": [{"name": "g", "type": "parallel", "nodes": [{"name": "y", "type": "pipeline", "config": {"pipeline": "a"}}]}]},
+			"a": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "b"}}]}
+		}}`, "recursive pipeline include: a -> b -> a"},
+		"self":    {`{"pipelines": {"a": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "a"}}]}}}`, "recursive pipeline include: a -> a"},
+		"missing": {`{"pipelines": {"a": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "nope"}}]}}}`, "pipeline 'a' includes unknown pipeline 'nope'"},
+		// 多处错误时按场景名报告第一处，每次加载的结果相同
+		"several missing": {`{"pipelines": {
+			"c": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "nope_c"}}]},
+			"b": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "nope_b"}}]},
+			"d": {"nodes": [{"name": "x", "type": "pipeline", "config": {"pipeline": "nope_d"}}]}
+		}}`, "pipeline 'b' includes unknown pipeline 'nope_b'"},
+	}
+	for name, tc := range cases {
+		path := writeConfig(t, tc.config)
+		for i := 0; i < 10; i++ {
+			_, err := NewEngine(path, newTestRegistry())
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+				break
+			}
+		}
+	}
+}
+
+func TestTraceRecordsNestedSpans(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"tail": {"nodes": [{"name": "dedup", "type": "drop", "config": {"items": ["a"]}}]},
+			"music": {
+				"nodes": [
+					{"name": "group", "type": "parallel", "nodes": [
+						{"name": "r1", "type": "stub", "config": {"items": ["a", "b"]}},
+						{"name": "r2", "type": "stub", "config": {"error": "down"}}
+					]},
+					{"name": "include_tail", "type": "pipeline", "config": {"pipeline": "tail"}}
+				]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	root := ctx.Trace()
+	if root == nil || root.Name != "music" || root.Status != SpanOK || len(root.Children) != 2 {
+		t.Fatalf("unexpected root span: %+v", root)
+	}
+	if r1 := root.Find("r1"); r1 == nil || r1.RecallSources["r1"] != 2 {
+		t.Errorf("expected r1 span with 2 recalled items, got %+v", r1)
+	}
+	if r2 := root.Find("r2"); r2 == nil || r2.Status != SpanError || r2.Error == "" {
+		t.Errorf("expected r2 span with error, got %+v", r2)
+	}
+	dedup := root.Find("dedup")
+	if dedup == nil || dedup.CandidatesBefore != 2 || dedup.CandidatesAfter != 1 {
+		t.Errorf("expected nested dedup span 2 -> 1, got %+v", dedup)
+	}
+	if include := root.Find("include_tail"); include == nil || include.Attributes["pipeline"] != "tail" || len(include.Children) != 1 {
+		t.Errorf("expected include_tail span to nest sub-pipeline nodes, got %+v", include)
+	}
+}
+
+// reasonDropNode 移除指定条目并上报原因
+type reasonDropNode struct {
+	dropNode
+}
+
+func (n *reasonDropNode) Execute(ctx *Context) error {
+	for _, item := range ctx.GetCandidates() {
+		if n.drop[item.Name] {
+			ctx.MarkRemoved(item, "blocked")
+		}
+	}
+	return n.dropNode.Execute(ctx)
+}
+
+func TestDebugSnapshots(t *testing.T) {
+	recall := &stubNode{name: "recall", items: []string{"a", "b", "c"}}
+	filter := &reasonDropNode{dropNode{name: "filter", drop: map[string]bool{"b": true}}}
+	plain := &dropNode{name: "plain", drop: map[string]bool{"c": true}}
+
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	ctx.Debug = true
+	for _, node := range []Node{recall, filter, plain} {
+		if err := runNode(ctx, node); err != nil {
+			t.Fatalf("runNode failed: %v", err)
+		}
+	}
+
+	snaps := ctx.Snapshots()
+	if len(snaps) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d", len(snaps))
+	}
+	if len(snaps[0].Added) != 3 || len(snaps[0].Candidates) != 3 {
+		t.Errorf("recall snapshot: expected 3 added, got %+v", snaps[0])
+	}
+	if len(snaps[1].Removed) != 1 || snaps[1].Removed[0].Name != "b" || snaps[1].Removed[0].Reason != "blocked" {
+		t.Errorf("filter snapshot: expected b removed with reason, got %+v", snaps[1].Removed)
+	}
+	if len(snaps[2].Removed) != 1 || snaps[2].Removed[0].Reason != "dropped by filter node plain" {
+		t.Errorf("plain snapshot: expected default reason, got %+v", snaps[2].Removed)
+	}
+
+	// 非 debug 模式不记录快照
+	ctx = NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := runNode(ctx, recall); err != nil {
+		t.Fatalf("runNode failed: %v", err)
+	}
+	if len(ctx.Snapshots()) != 0 {
+		t.Error("expected no snapshots outside debug mode")
+	}
+
+	// parallel 的子节点都写入后才返回，各自的快照只包含自身的修改
+	var wg sync.WaitGroup
+	wg.Add(2)
+	group := NewParallelNode("group", []Node{
+		&barrierRecallNode{stubNode{name: "left", items: []string{"x"}}, &wg},
+		&barrierRecallNode{stubNode{name: "right", items: []string{"y"}}, &wg},
+	}, ParallelPolicy{Kind: PolicyAll})
+	ctx = NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	ctx.Debug = true
+	if err := runNode(ctx, group); err != nil {
+		t.Fatalf("runNode failed: %v", err)
+	}
+	added := make(map[string]string)
+	for _, snap := range ctx.Snapshots() {
+		added[snap.Node] = strings.Join(snap.Added, ",")
+	}
+	if added["left"] != "x" || added["right"] != "y" {
+		t.Errorf("expected each child to report only its own items, got %v", added)
+	}
+	if got := added["group"]; got != "x,y" && got != "y,x" {
+		t.Errorf("expected parallel snapshot to include both children, got %q", got)
+	}
+}
+
+// barrierRecallNode 写入召回结果后等待所有兄弟节点写入再返回
+type barrierRecallNode struct {
+	stubNode
+	wg *sync.WaitGroup
+}
+
+func (n *barrierRecallNode) Execute(ctx *Context) error {
+	err := n.stubNode.Execute(ctx)
+	n.wg.Done()
+	n.wg.Wait()
+	return err
+}
+
+// panicNode 执行时 panic
+type panicNode struct{ name string }
+
+func (n *panicNode) Name() string { return n.name }
+func (n *panicNode) Type() string { return "recall" }
+func (n *panicNode) Execute(ctx *Context) error {
+	panic("boom")
+}
+
+func TestInterceptors(t *testing.T) {
+	registry := newTestRegistry()
+	registry.Register("panic", func(cfg NodeConfig) (Node, error) {
+		return &panicNode{name: cfg.Name}, nil
+	})
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [
+		{"name": "recall", "type": "parallel", "nodes": [
+			{"name": "a", "type": "stub", "config": {"items": ["x"]}},
+			{"name": "bad", "type": "panic"}
+		]},
+		{"name": "top_panic", "type": "panic"}
+	]}}}`)
+
+	engine, err := NewEngine(path, registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	engine.Use(func(ctx *Context, node Node, next Handler) error {
+		mu.Lock()
+		order = append(order, node.Name())
+		mu.Unlock()
+		return next(ctx)
+	})
+
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	err = engine.Run(ctx, "music")
+	if err == nil || !strings.Contains(err.Error(), "node top_panic panicked: boom") {
+		t.Fatalf("expected top-level panic to be recovered as error, got %v", err)
+	}
+
+	// parallel 子节点的 panic 被恢复为失败，按 any 策略整体成功
+	if got := candidateNames(ctx); len(got) != 1 || got[0] != "x" {
+		t.Errorf("expected candidates [x], got %v", got)
+	}
+	if span := ctx.Trace().Find("bad"); span == nil || span.Status != SpanError {
+		t.Errorf("expected panicking child span to be marked as error, got %+v", span)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	seen := make(map[string]bool)
+	for _, name := range order {
+		seen[name] = true
+	}
+	for _, name := range []string{"recall", "a", "bad", "top_panic"} {
+		if !seen[name] {
+			t.Errorf("expected interceptor to see node %s, got %v", name, order)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package workflow
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// ExperimentConfig 场景级 A/B 实验的配置
+// 声明实验的场景不再使用顶层的 nodes，而是为每个用户选择一个变体执行其节点列表
+type ExperimentConfig struct {
+	Name     string          `json:"name" yaml:"name"`                     // 实验名称，为空时使用场景名
+	Salt     string          `json:"salt,omitempty" yaml:"salt,omitempty"` // 分流盐值，为空时使用实验名称；修改后用户会被重新分组
+	Variants []VariantConfig `json:"variants" yaml:"variants"`
+}
+
+// VariantConfig 实验中的一个变体
+type VariantConfig struct {
+	Name   string       `json:"name" yaml:"name"`
+	Weight int          `json:"weight" yaml:"weight"` // 流量权重，按所有变体权重之和计算占比；0 表示不分配流量
+	Nodes  []NodeConfig `json:"nodes" yaml:"nodes"`
+}
+
+// NodeLists 返回 Pipeline 的所有顶层节点列表：未声明实验时为 nodes，否则为每个变体的 nodes
+func (p PipelineConfig) NodeLists() [][]NodeConfig {
+	if p.Experiment == nil {
+		return [][]NodeConfig{p.Nodes}
+	}
+	lists := make([][]NodeConfig, len(p.Experiment.Variants))
+	for i, v := range p.Experiment.Variants {
+		lists[i] = v.Nodes
+	}
+	return lists
+}
+
+// experiment 是加载后的实验
+type experiment struct {
+	name        string
+	salt        string
+	variants    []*variant
+	totalWeight uint64
+}
+
+// variant 是加载后的变体
+type variant struct {
+	name   string
+	weight uint64
+	nodes  []Node
+}
+
+// buildExperiment 校验实验配置并构建每个变体的节点
+func buildExperiment(scene string, cfg *ExperimentConfig, registry *Registry) (*experiment, error) {
+	exp := &experiment{name: cfg.Name, salt: cfg.Salt}
+	if exp.name == "" {
+		exp.name = scene
+	}
+	if exp.salt == "" {
+		exp.salt = exp.name
+	}
+	if len(cfg.Variants) == 0 {
+		return nil, withPath("variants", fmt.Errorf("experiment has no variants"))
+	}
+
+	var errs ConfigErrors
+	seen := make(map[string]bool, len(cfg.Variants))
+	for i, vCfg := range cfg.Variants {
+		path := fmt.Sprintf("variants[%d]", i)
+		switch {
+		case vCfg.Name == "":
+			errs = append(errs, withPath(path+".name", fmt.Errorf("required field is missing")))
+		case seen[vCfg.Name]:
+			errs = append(errs, withPath(path+".name", fmt.Errorf("duplicate variant name '%s'", vCfg.Name)))
+		}
+		seen[vCfg.Name] = true
+		if vCfg.Weight < 0 {
+			errs = append(errs, withPath(path+".weight", fmt.Errorf("must not be negative, got %d", vCfg.Weight)))
+			continue
+		}
+
+		nodes, err := buildNodes(scene, vCfg.Nodes, registry)
+		if err != nil {
+			errs = errs.append(withPath(path, err))
+			continue
+		}
+		exp.variants = append(exp.variants, &variant{name: vCfg.Name, weight: uint64(vCfg.Weight), nodes: nodes})
+		exp.totalWeight += uint64(vCfg.Weight)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if exp.totalWeight == 0 {
+		return nil, withPath("variants", fmt.Errorf("total weight of variants must be positive"))
+	}
+	return exp, nil
+}
+
+// assign 为用户选择变体
+// 分组只取决于盐值和用户 ID，同一用户在配置不变时总是落在同一个变体
+func (e *experiment) assign(userID string) *variant {
+	h := fnv.New64a()
+	h.Write([]byte(e.salt))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	bucket := h.Sum64() % e.totalWeight
+
+	for _, v := range e.variants {
+		if bucket < v.weight {
+			return v
+		}
+		bucket -= v.weight
+	}
+	// 权重之和等于 totalWeight，不会执行到这里
+	return e.variants[len(e.variants)-1]
+}
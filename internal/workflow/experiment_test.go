@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"recommend_engine/internal/model"
+)
+
+func TestExperimentVariants(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"experiment": {
+					"name": "rank_test",
+					"salt": "2026-10",
+					"variants": [
+						{"name": "control", "weight": 1, "nodes": [{"name": "recall", "type": "stub", "config": {"items": ["a"]}}]},
+						{"name": "treatment", "weight": 3, "nodes": [{"name": "recall", "type": "stub", "config": {"items": ["b"]}}]},
+						{"name": "off", "weight": 0, "nodes": [{"name": "recall", "type": "stub", "config": {"items": ["c"]}}]}
+					]
+				}
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user_%d", i)
+		ctx := NewContext(context.Background(), userID, &model.User{ID: userID})
+		if err := engine.Run(ctx, "music"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		v := ctx.Experiments()["rank_test"]
+		counts[v]++
+
+		// 候选集来自选中变体的节点，轨迹中记录实验和变体
+		want := map[string]string{"control": "a", "treatment": "b"}[v]
+		if got := candidateNames(ctx); len(got) != 1 || got[0] != want {
+			t.Fatalf("%s: variant %s produced %v", userID, v, got)
+		}
+		if attrs := ctx.Trace().Attributes; attrs["experiment"] != "rank_test" || attrs["variant"] != v {
+			t.Fatalf("%s: unexpected trace attributes %v", userID, attrs)
+		}
+
+		// 同一用户再次请求总是落在同一个变体
+		again := NewContext(context.Background(), userID, &model.User{ID: userID})
+		engine.Run(again, "music")
+		if got := again.Experiments()["rank_test"]; got != v {
+			t.Fatalf("%s: assignment not deterministic: %s then %s", userID, v, got)
+		}
+	}
+
+	if counts["off"] != 0 {
+		t.Errorf("weight 0 variant received %d users", counts["off"])
+	}
+	if counts["control"] < 180 || counts["control"] > 320 {
+		t.Errorf("expected about 250 users in control, got %v", counts)
+	}
+}
+
+func TestExperimentConfigErrors(t *testing.T) {
+	cases := map[string]struct {
+		experiment string
+		want       string
+	}{
+		"no variants": {
+			`"experiment": {"variants": []}`,
+			"pipelines.music.experiment.variants: experiment has no variants",
+		},
+		"duplicate name": {
+			`"experiment": {"variants": [
+				{"name": "a", "weight": 1, "nodes": []},
+				{"name": "a", "weight": 1, "nodes": []}
+			]}`,
+			"pipelines.music.experiment.variants[1].name: duplicate variant name 'a'",
+		},
+		"zero weight": {
+			`"experiment": {"variants": [{"name": "a", "weight": 0, "nodes": []}]}`,
+			"pipelines.music.experiment.variants: total weight of variants must be positive",
+		},
+		"bad node": {
+			`"experiment": {"variants": [{"name": "a", "weight": 1, "nodes": [{"name": "x", "type": "nope"}]}]}`,
+			"pipelines.music.experiment.variants[0].nodes[0]: unknown node type: nope",
+		},
+		"with nodes": {
+			`"nodes": [{"name": "x", "type": "stub"}], "experiment": {"variants": [{"name": "a", "weight": 1, "nodes": []}]}`,
+			"pipelines.music.nodes: nodes cannot be combined with experiment",
+		},
+	}
+	for name, tc := range cases {
+		_, err := NewEngine(writeConfig(t, `{"pipelines": {"music": {`+tc.experiment+`}}}`), newTestRegistry())
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
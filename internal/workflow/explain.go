@@ -0,0 +1,136 @@
+package workflow
+
+import (
+	"fmt"
+	"sync"
+
+	"recommend_engine/internal/model"
+)
+
+// Removal 记录一个被节点移除的条目及原因
+type Removal struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Snapshot 是 debug 模式下某个节点执行后候选集的快照
+type Snapshot struct {
+	Node       string       `json:"node"`
+	Type       string       `json:"type"`
+	Status     string       `json:"status"`
+	Candidates []model.Item `json:"candidates"`
+	Added      []string     `json:"added,omitempty"`   // 新增条目的 ID
+	Removed    []Removal    `json:"removed,omitempty"` // 被移除的条目及原因
+}
+
+// removalLog 收集单个节点执行期间通过 MarkRemoved 上报的移除原因
+// 原因同时会上报给外层组合节点的 removalLog
+type removalLog struct {
+	mu      sync.Mutex
+	reasons map[string]string // item key -> reason
+	parent  *removalLog
+}
+
+// MarkRemoved 记录条目被移除的原因，仅在 debug 模式下生效
+// 过滤类节点应当在移除条目时调用，用于解释推荐结果
+func (c *Context) MarkRemoved(item *model.Item, reason string) {
+	key := itemKey(item)
+	for log := c.removals; log != nil; log = log.parent {
+		log.mu.Lock()
+		log.reasons[key] = reason
+		log.mu.Unlock()
+	}
+}
+
+// Snapshots 返回 debug 模式下记录的所有快照，按节点完成顺序排列
+func (c *Context) Snapshots() []Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]Snapshot, len(c.snapshots))
+	copy(result, c.snapshots)
+	return result
+}
+
+// withRemovals 返回一个收集移除原因的浅拷贝
+func (c *Context) withRemovals(log *removalLog) *Context {
+	cp := *c
+	cp.removals = log
+	return &cp
+}
+
+// addSnapshot 记录快照，parallel 子节点中写入缓冲区
+func (c *Context) addSnapshot(s Snapshot) {
+	if b := c.buffer; b != nil {
+		b.mu.Lock()
+		b.snapshots = append(b.snapshots, s)
+		b.mu.Unlock()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshots = append(c.snapshots, s)
+}
+
+// itemKey 用于对比前后候选集，优先使用 ID
+func itemKey(item *model.Item) string {
+	if item.ID != "" {
+		return item.ID
+	}
+	return item.Name
+}
+
+// copyItems 深拷贝候选集，避免后续节点修改分数等字段影响快照
+func copyItems(items []*model.Item) []model.Item {
+	result := make([]model.Item, len(items))
+	for i, item := range items {
+		result[i] = *item
+		if item.MetaData != nil {
+			result[i].MetaData = make(map[string]interface{}, len(item.MetaData))
+			for k, v := range item.MetaData {
+				result[i].MetaData[k] = v
+			}
+		}
+	}
+	return result
+}
+
+// buildSnapshot 对比节点执行前后的候选集，生成快照
+// 同一 ID 的条目可能出现多次 (多路召回重复)，按出现次数计算增减
+func buildSnapshot(node Node, status string, before, after []*model.Item, log *removalLog) Snapshot {
+	counts := make(map[string]int, len(before))
+	for _, item := range before {
+		counts[itemKey(item)]++
+	}
+
+	snap := Snapshot{
+		Node:       node.Name(),
+		Type:       node.Type(),
+		Status:     status,
+		Candidates: copyItems(after),
+	}
+	for _, item := range after {
+		key := itemKey(item)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		snap.Added = append(snap.Added, key)
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	for _, item := range before {
+		key := itemKey(item)
+		if counts[key] == 0 {
+			continue
+		}
+		counts[key]--
+		reason, ok := log.reasons[key]
+		if !ok {
+			reason = fmt.Sprintf("dropped by %s node %s", node.Type(), node.Name())
+		}
+		snap.Removed = append(snap.Removed, Removal{ID: item.ID, Name: item.Name, Reason: reason})
+	}
+	return snap
+}
@@ -0,0 +1,274 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 流程图的输出格式
+const (
+	GraphDOT     = "dot"     // Graphviz DOT
+	GraphMermaid = "mermaid" // Mermaid flowchart
+)
+
+// graphConfigKeys 是节点标签中展示的关键配置项
+var graphConfigKeys = []string{"llm_config_key", "count", "limit", "lookback_days", "order", "mix_count", "policy", "pipeline", "stage", "mode", "drop_if", "keep_if", "expr", "when"}
+
+// graphNode 是流程图中的一个节点
+type graphNode struct {
+	id    string
+	lines []string // 标签的每一行
+	shape string   // "start" 表示场景入口，其余为普通节点
+	error bool     // 最近一次执行失败
+}
+
+// graphEdge 是流程图中的一条边
+type graphEdge struct {
+	from, to string
+	label    string
+}
+
+// graph 是 Pipeline 配置对应的流程图
+type graph struct {
+	scene string
+	nodes []*graphNode
+	edges []graphEdge
+	trace *Span
+}
+
+// RenderGraph 将场景的 Pipeline 配置渲染为 DOT 或 Mermaid 流程图
+// 节点标签包含类型和关键配置；trace 不为空时附带该次执行中每个节点的耗时
+//
+// 顺序执行的节点依次相连，声明了 depends_on 的 Pipeline 按依赖关系连线；
+// parallel / fallback 节点连向每个子节点，switch 节点按分支名连向各分支的节点序列，
+// 声明了实验的场景由入口按变体连向各变体的节点序列。
+func RenderGraph(scene string, cfg PipelineConfig, format string, trace *Span) (string, error) {
+	g := &graph{scene: scene, trace: trace}
+	start := g.addNode(&graphNode{lines: []string{scene}, shape: "start"})
+	if cfg.Description != "" {
+		start.lines = append(start.lines, cfg.Description)
+	}
+	if trace != nil {
+		start.lines = append(start.lines, formatLatency(trace))
+		start.error = trace.Status == SpanError
+	}
+	if cfg.Experiment == nil {
+		g.addSequence(start.id, "", cfg.Nodes)
+	} else {
+		// 实验场景：入口按变体名和流量占比连向各变体的节点序列
+		total := 0
+		for _, v := range cfg.Experiment.Variants {
+			total += v.Weight
+		}
+		// 轨迹只对应选中的变体，其他变体中的同名节点不展示耗时
+		chosen := ""
+		if trace != nil {
+			chosen = trace.Attributes["variant"]
+		}
+		for _, v := range cfg.Experiment.Variants {
+			label := v.Name
+			if total > 0 {
+				label = fmt.Sprintf("%s (%g%%)", v.Name, float64(v.Weight)*100/float64(total))
+			}
+			g.trace = nil
+			if v.Name == chosen {
+				g.trace = trace
+			}
+			g.addSequence(start.id, label, v.Nodes)
+		}
+	}
+
+	switch format {
+	case GraphDOT:
+		return g.dot(), nil
+	case GraphMermaid:
+		return g.mermaid(), nil
+	default:
+		return "", fmt.Errorf("unknown graph format: %s (expected %s or %s)", format, GraphDOT, GraphMermaid)
+	}
+}
+
+func (g *graph) addNode(n *graphNode) *graphNode {
+	n.id = fmt.Sprintf("n%d", len(g.nodes))
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+// addSequence 添加一组同级节点，from 为前驱节点，label 为连向第一个节点的边的标签
+func (g *graph) addSequence(from, label string, cfgs []NodeConfig) {
+	dag := false
+	for _, cfg := range cfgs {
+		if len(cfg.DependsOn) > 0 {
+			dag = true
+		}
+	}
+
+	ids := make(map[string]string, len(cfgs))
+	prev := from
+	for _, cfg := range cfgs {
+		// 子节点的边在 addConfigNode 中添加，把连向该节点的边插到它们之前，保证输出按执行顺序排列
+		pos := len(g.edges)
+		id := g.addConfigNode(cfg)
+		ids[cfg.Name] = id
+		if dag {
+			continue
+		}
+		g.edges = append(g.edges[:pos], append([]graphEdge{{from: prev, to: id, label: label}}, g.edges[pos:]...)...)
+		prev, label = id, ""
+	}
+	if !dag {
+		return
+	}
+
+	// DAG：没有依赖的节点连向前驱，其余节点按 depends_on 连线
+	for _, cfg := range cfgs {
+		if len(cfg.DependsOn) == 0 {
+			g.edges = append(g.edges, graphEdge{from: from, to: ids[cfg.Name], label: label})
+			continue
+		}
+		for _, dep := range cfg.DependsOn {
+			if depID, ok := ids[dep]; ok {
+				g.edges = append(g.edges, graphEdge{from: depID, to: ids[cfg.Name]})
+			}
+		}
+	}
+}
+
+// addConfigNode 添加一个节点及其子节点，返回节点 ID
+func (g *graph) addConfigNode(cfg NodeConfig) string {
+	n := g.addNode(&graphNode{lines: nodeLabel(cfg)})
+	if g.trace != nil {
+		if span := g.trace.Find(cfg.Name); span != nil {
+			n.lines = append(n.lines, formatLatency(span))
+			n.error = span.Status == SpanError
+		}
+	}
+
+	switch cfg.Type {
+	case "parallel", "fallback":
+		for i, child := range cfg.Nodes {
+			label := ""
+			if cfg.Type == "fallback" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			g.addSequence(n.id, label, []NodeConfig{child})
+		}
+	case "switch":
+		for i, c := range cfg.Cases {
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("case_%d", i)
+			}
+			g.addSequence(n.id, name, c.Nodes)
+		}
+		if len(cfg.Default) > 0 {
+			g.addSequence(n.id, "default", cfg.Default)
+		}
+	}
+	return n.id
+}
+
+// nodeLabel 返回节点的名称、类型和关键配置
+func nodeLabel(cfg NodeConfig) []string {
+	lines := []string{cfg.Name, "(" + cfg.Type + ")"}
+
+	var params []string
+	for _, key := range graphConfigKeys {
+		if v, ok := cfg.Config[key]; ok {
+			params = append(params, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+	if cfg.TimeoutMs > 0 {
+		params = append(params, fmt.Sprintf("timeout_ms=%d", cfg.TimeoutMs))
+	}
+	if cfg.Retry != nil {
+		params = append(params, fmt.Sprintf("retry=%d", cfg.Retry.MaxAttempts))
+	}
+	sort.Strings(params)
+	return append(lines, params...)
+}
+
+func formatLatency(span *Span) string {
+	if span.Status == SpanError {
+		return fmt.Sprintf("%.1fms (error)", span.DurationMs)
+	}
+	return fmt.Sprintf("%.1fms", span.DurationMs)
+}
+
+func (g *graph) dot() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.scene))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range g.nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(n.lines, "\n"))}
+		if n.shape == "start" {
+			attrs = append(attrs, "shape=oval")
+		}
+		if n.error {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", n.id, strings.Join(attrs, ", "))
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", e.from, e.to, dotQuote(e.label))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.from, e.to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (g *graph) mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, n := range g.nodes {
+		label := mermaidQuote(n.lines)
+		if n.shape == "start" {
+			fmt.Fprintf(&b, "  %s([%s])\n", n.id, label)
+		} else {
+			fmt.Fprintf(&b, "  %s[%s]\n", n.id, label)
+		}
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			fmt.Fprintf(&b, "  %s -->|%s| %s\n", e.from, mermaidQuote([]string{e.label}), e.to)
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", e.from, e.to)
+		}
+	}
+	var failed []string
+	for _, n := range g.nodes {
+		if n.error {
+			failed = append(failed, n.id)
+		}
+	}
+	if len(failed) > 0 {
+		b.WriteString("  classDef error stroke:#d33,stroke-width:2px\n")
+		fmt.Fprintf(&b, "  class %s error\n", strings.Join(failed, ","))
+	}
+	return b.String()
+}
+
+// dotQuote 转义 DOT 字符串，换行使用 \n
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidQuote 转义 Mermaid 标签，多行使用 <br/> 连接
+func mermaidQuote(lines []string) string {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.ReplaceAll(line, `"`, "#quot;")
+		line = strings.ReplaceAll(line, "<", "#lt;")
+		line = strings.ReplaceAll(line, ">", "#gt;")
+		escaped[i] = line
+	}
+	return `"` + strings.Join(escaped, "<br/>") + `"`
+}
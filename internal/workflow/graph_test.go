@@ -0,0 +1,59 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderGraph(t *testing.T) {
+	cfg := PipelineConfig{Nodes: []NodeConfig{
+		{Name: "recall", Type: "parallel", Nodes: []NodeConfig{
+			{Name: "llm", Type: "recall_llm", Config: map[string]interface{}{"llm_config_key": "doubao", "count": float64(50)}},
+			{Name: "hot", Type: "recall_static"},
+		}},
+		{Name: "route", Type: "switch", Cases: []CaseConfig{
+			{Name: "vip", Nodes: []NodeConfig{{Name: "vip_rank", Type: "rank_simple", Config: map[string]interface{}{"limit": float64(10)}}}},
+		}, Default: []NodeConfig{{Name: "rank", Type: "rank_simple"}}},
+	}}
+
+	trace := newSpan("music", "pipeline", 0)
+	trace.startChild("llm", "recall", 0).finish(5, nil)
+	trace.finish(5, nil)
+
+	dot, err := RenderGraph("music", cfg, GraphDOT, trace)
+	if err != nil {
+		t.Fatalf("RenderGraph failed: %v", err)
+	}
+	for _, want := range []string{
+		`digraph "music" {`,
+		`n2 [label="llm\n(recall_llm)\ncount=50\nllm_config_key=doubao\n`,
+		"n0 -> n1;",
+		"n1 -> n2;",
+		"n1 -> n4;",
+		`n4 -> n5 [label="vip"];`,
+		`n4 -> n6 [label="default"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := RenderGraph("music", cfg, GraphMermaid, nil)
+	if err != nil {
+		t.Fatalf("RenderGraph failed: %v", err)
+	}
+	for _, want := range []string{
+		"flowchart LR",
+		`n0(["music"])`,
+		`n5["vip_rank<br/>(rank_simple)<br/>limit=10"]`,
+		`n4 -->|"vip"| n5`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	if _, err := RenderGraph("music", cfg, "svg", nil); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
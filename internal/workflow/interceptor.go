@@ -0,0 +1,68 @@
+package workflow
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"recommend_engine/internal/logger"
+)
+
+// Handler 执行一个节点，是拦截器链中的下一环
+type Handler func(ctx *Context) error
+
+// Interceptor 包裹每一次 Node.Execute 调用 (包括顶层节点和组合节点的子节点)
+// 拦截器可以在调用 next 前后添加逻辑，也可以不调用 next 直接返回错误
+// 用于实现日志、panic 恢复、耗时统计、指标上报等横切逻辑，而无需修改每个节点
+type Interceptor func(ctx *Context, node Node, next Handler) error
+
+// DefaultInterceptors 返回 Engine 默认启用的拦截器
+func DefaultInterceptors() []Interceptor {
+	return []Interceptor{RecoveryInterceptor, TimingInterceptor}
+}
+
+// RecoveryInterceptor 将节点中的 panic 转换为错误，防止单个节点导致进程崩溃
+func RecoveryInterceptor(ctx *Context, node Node, next Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Node %s panicked: %v\n%s", node.Name(), r, debug.Stack())
+			err = fmt.Errorf("node %s panicked: %v", node.Name(), r)
+		}
+	}()
+	return next(ctx)
+}
+
+// TimingInterceptor 记录节点的执行耗时
+func TimingInterceptor(ctx *Context, node Node, next Handler) error {
+	start := time.Now()
+	err := next(ctx)
+	elapsed := time.Since(start)
+	if err != nil {
+		ctx.AddLog(fmt.Sprintf("Node %s (%s) failed after %v", node.Name(), node.Type(), elapsed))
+	} else {
+		ctx.AddLog(fmt.Sprintf("Node %s (%s) finished in %v", node.Name(), node.Type(), elapsed))
+	}
+	return err
+}
+
+// chain 按注册顺序组装拦截器，第一个拦截器位于最外层
+func chain(interceptors []Interceptor, node Node) Handler {
+	h := Handler(node.Execute)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx *Context) error {
+			return interceptor(ctx, node, next)
+		}
+	}
+	return h
+}
+
+// execute 通过拦截器链执行节点
+// 未经 Engine 执行的 Context (如单测中直接构造的) 使用默认拦截器
+func execute(ctx *Context, node Node) error {
+	interceptors := ctx.interceptors
+	if interceptors == nil {
+		interceptors = DefaultInterceptors()
+	}
+	return chain(interceptors, node)(ctx)
+}
@@ -0,0 +1,342 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"recommend_engine/internal/logger"
+)
+
+// DefaultInitTimeout 未配置 init_timeout_ms 时所有节点 Init 的总超时时间
+const DefaultInitTimeout = 30 * time.Second
+
+// SlowWarmupThreshold 超过该耗时的 Init 在就绪检查中标记为慢
+const SlowWarmupThreshold = time.Second
+
+// ErrEngineClosed 表示 Engine 已经关闭，不再接受请求
+var ErrEngineClosed = errors.New("engine is closed")
+
+// Initializer 由需要在处理请求前加载状态 (索引、连接池、文件句柄等) 的节点实现
+// 引擎构建完所有 Pipeline 后、新配置生效前并发调用 Init，ctx 带有 init_timeout_ms 的超时。
+// 任一节点 Init 失败时整份配置加载失败 (热更新时旧配置继续生效)。
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// Closer 由持有需要释放的资源的节点实现
+// 配置被热更新替换、且使用旧配置的请求全部结束后调用；Engine.Close 时同样会调用。
+// Init 失败或超时导致配置被丢弃时，也会关闭该配置中的所有节点。
+type Closer interface {
+	Close() error
+}
+
+// 组合节点通过以下方法暴露子节点，生命周期钩子会递归调用子节点的 Init / Close：
+// 包装单个节点 (如超时、重试) 的实现 Unwrap，包含多个子节点的实现 Children。
+type (
+	nodeWrapper interface {
+		Unwrap() Node
+	}
+	nodeComposite interface {
+		Children() []Node
+	}
+)
+
+// walkNodes 深度优先遍历节点及其子节点
+// pipeline 节点引用的子流程属于另一个场景，不会被遍历
+func walkNodes(nodes []Node, fn func(Node)) {
+	for _, node := range nodes {
+		fn(node)
+		switch n := node.(type) {
+		case nodeWrapper:
+			walkNodes([]Node{n.Unwrap()}, fn)
+		case nodeComposite:
+			walkNodes(n.Children(), fn)
+		}
+	}
+}
+
+// scopedNode 是某个场景中的节点
+type scopedNode struct {
+	scene string
+	node  Node
+}
+
+// allNodes 返回流程集合中的所有节点 (包括实验变体和组合节点的子节点)，按场景名排序
+func (s *pipelineSet) allNodes() []scopedNode {
+	scenes := make([]string, 0, len(s.pipelines))
+	for scene := range s.pipelines {
+		scenes = append(scenes, scene)
+	}
+	sort.Strings(scenes)
+
+	var result []scopedNode
+	for _, scene := range scenes {
+		p := s.pipelines[scene]
+		lists := [][]Node{p.nodes}
+		if p.experiment != nil {
+			lists = nil
+			for _, v := range p.experiment.variants {
+				lists = append(lists, v.nodes)
+			}
+		}
+		for _, nodes := range lists {
+			walkNodes(nodes, func(n Node) {
+				result = append(result, scopedNode{scene: scene, node: n})
+			})
+		}
+	}
+	return result
+}
+
+// WarmupStat 是单个节点 Init 的执行情况
+type WarmupStat struct {
+	Scene      string  `json:"scene"`
+	Node       string  `json:"node"`
+	Type       string  `json:"type"`
+	DurationMs float64 `json:"duration_ms"` // 进行中时为已经过的时间
+	Done       bool    `json:"done"`
+	Slow       bool    `json:"slow"` // 耗时超过 SlowWarmupThreshold
+	Error      string  `json:"error,omitempty"`
+
+	start time.Time
+}
+
+// initNodes 并发调用流程集合中所有节点的 Init
+// 任一节点失败或整体超时时返回 ConfigErrors，并在所有 Init 返回后关闭该流程集合
+func (s *pipelineSet) initNodes() error {
+	timeout := DefaultInitTimeout
+	if s.config.InitTimeoutMs > 0 {
+		timeout = time.Duration(s.config.InitTimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, entry := range s.allNodes() {
+		initializer, ok := entry.node.(Initializer)
+		if !ok {
+			continue
+		}
+		stat := &WarmupStat{Scene: entry.scene, Node: entry.node.Name(), Type: entry.node.Type(), start: time.Now()}
+		s.warmupMu.Lock()
+		s.warmups = append(s.warmups, stat)
+		s.warmupMu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := initializer.Init(ctx)
+			elapsed := time.Since(stat.start)
+
+			s.warmupMu.Lock()
+			defer s.warmupMu.Unlock()
+			stat.Done = true
+			stat.DurationMs = float64(elapsed.Microseconds()) / 1000
+			stat.Slow = elapsed > SlowWarmupThreshold
+			if err != nil {
+				stat.Error = err.Error()
+			}
+		}()
+	}
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	select {
+	case <-allDone:
+	case <-ctx.Done():
+		// 不响应 ctx 的 Init 可能仍在执行，等它们返回后再关闭节点
+		go func() {
+			<-allDone
+			s.close()
+		}()
+		var errs ConfigErrors
+		for _, stat := range s.Warmups() {
+			if !stat.Done {
+				errs = append(errs, &ConfigError{Path: "pipelines." + stat.Scene, Err: fmt.Errorf("node '%s' init did not finish within %v", stat.Node, timeout)})
+			}
+		}
+		return errs
+	}
+
+	var errs ConfigErrors
+	for _, stat := range s.Warmups() {
+		if stat.Error != "" {
+			errs = append(errs, &ConfigError{Path: "pipelines." + stat.Scene, Err: fmt.Errorf("node '%s' init failed: %s", stat.Node, stat.Error)})
+		}
+	}
+	if len(errs) > 0 {
+		s.close()
+		return errs
+	}
+	return nil
+}
+
+// Warmups 返回各节点 Init 的执行情况，进行中的条目包含已经过的时间
+func (s *pipelineSet) Warmups() []WarmupStat {
+	s.warmupMu.Lock()
+	defer s.warmupMu.Unlock()
+	result := make([]WarmupStat, len(s.warmups))
+	for i, stat := range s.warmups {
+		result[i] = *stat
+		if !stat.Done {
+			elapsed := time.Since(stat.start)
+			result[i].DurationMs = float64(elapsed.Microseconds()) / 1000
+			result[i].Slow = elapsed > SlowWarmupThreshold
+		}
+	}
+	return result
+}
+
+// acquire 登记一个使用该流程集合的请求，集合已经关闭或引擎正在关闭时返回 false
+func (s *pipelineSet) acquire() bool {
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	if s.closed || s.stopped {
+		return false
+	}
+	s.refs++
+	return true
+}
+
+// retain 为已经持有引用的请求追加一个引用，用于请求返回后仍在后台执行节点的 goroutine
+func (s *pipelineSet) retain() {
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	s.refs++
+}
+
+// release 结束一个请求，集合已被替换且没有其他请求时关闭它
+func (s *pipelineSet) release() {
+	s.refMu.Lock()
+	s.refs--
+	idle := s.retired && s.refs == 0
+	s.refMu.Unlock()
+	if idle {
+		s.close()
+	}
+}
+
+// stop 拒绝之后的 acquire，已经登记的请求继续执行
+func (s *pipelineSet) stop() {
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	s.stopped = true
+}
+
+// retire 标记集合已被替换，没有正在执行的请求时立即关闭
+func (s *pipelineSet) retire() {
+	s.refMu.Lock()
+	s.retired = true
+	idle := s.refs == 0
+	s.refMu.Unlock()
+	if idle {
+		s.close()
+	}
+}
+
+// close 调用所有节点的 Close，只执行一次
+func (s *pipelineSet) close() {
+	s.closeOnce.Do(func() {
+		s.refMu.Lock()
+		s.closed = true
+		s.refMu.Unlock()
+
+		for _, entry := range s.allNodes() {
+			closer, ok := entry.node.(Closer)
+			if !ok {
+				continue
+			}
+			if err := closer.Close(); err != nil {
+				logger.Error("Failed to close node %s in pipeline %s: %v", entry.node.Name(), entry.scene, err)
+			}
+		}
+		close(s.done)
+	})
+}
+
+// acquirePipelines 获取当前生效的流程集合并登记本次请求
+// 与热更新并发时可能拿到刚被关闭的集合，此时重新获取
+func (e *Engine) acquirePipelines() (*pipelineSet, error) {
+	for {
+		set := e.pipelines()
+		if set.acquire() {
+			return set, nil
+		}
+		if e.isClosed() {
+			return nil, ErrEngineClosed
+		}
+	}
+}
+
+// isClosed 不获取 reloadMu，热更新执行 Init 期间也能立即返回
+func (e *Engine) isClosed() bool {
+	return atomic.LoadInt32(&e.closed) == 1
+}
+
+// Close 关闭引擎：不再接受新的请求，等待正在执行的请求 (包括异步任务和影子流程) 结束后
+// 调用所有节点的 Close。ctx 到期时不再等待并返回 ctx.Err()，节点会在请求结束后被关闭。
+func (e *Engine) Close(ctx context.Context) error {
+	e.reloadMu.Lock()
+	if e.isClosed() {
+		e.reloadMu.Unlock()
+		return nil
+	}
+	atomic.StoreInt32(&e.closed, 1)
+	set := e.pipelines()
+	e.reloadMu.Unlock()
+
+	set.stop()
+	set.retire()
+	select {
+	case <-set.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Readiness 描述引擎是否可以处理请求
+type Readiness struct {
+	Ready   bool         `json:"ready"`
+	Reason  string       `json:"reason,omitempty"`
+	Warmups []WarmupStat `json:"warmups,omitempty"` // 当前配置中各节点 Init 的耗时，按耗时降序
+	Pending []WarmupStat `json:"pending,omitempty"` // 正在热更新的配置中尚未完成的 Init
+}
+
+// Readiness 返回引擎的就绪状态以及节点预热的耗时
+func (e *Engine) Readiness() Readiness {
+	if e.isClosed() {
+		return Readiness{Ready: false, Reason: ErrEngineClosed.Error()}
+	}
+
+	r := Readiness{Ready: true, Warmups: e.pipelines().Warmups()}
+	sort.SliceStable(r.Warmups, func(i, j int) bool { return r.Warmups[i].DurationMs > r.Warmups[j].DurationMs })
+
+	if loading, ok := e.loading.Load().(*pipelineSet); ok && loading != nil {
+		for _, stat := range loading.Warmups() {
+			if !stat.Done {
+				r.Pending = append(r.Pending, stat)
+			}
+		}
+	}
+	var slow []string
+	for _, stat := range append(append([]WarmupStat{}, r.Warmups...), r.Pending...) {
+		if stat.Slow {
+			slow = append(slow, fmt.Sprintf("%s/%s (%.0fms)", stat.Scene, stat.Node, stat.DurationMs))
+		}
+	}
+	if len(slow) > 0 {
+		r.Reason = "slow warm-up: " + strings.Join(slow, ", ")
+	}
+	return r
+}
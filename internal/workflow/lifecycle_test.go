@@ -0,0 +1,350 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+)
+
+// resourceNode 是测试用的带生命周期的节点
+type resourceNode struct {
+	name     string
+	initErr  error
+	initWait time.Duration // Init 忽略 ctx 阻塞的时间
+	gate     chan struct{} // 不为空时 Execute 阻塞直到被关闭
+	entered  chan struct{} // 不为空时 Execute 开始后关闭
+
+	mu          sync.Mutex
+	initialized bool
+	closed      bool
+}
+
+func (n *resourceNode) Name() string { return n.name }
+func (n *resourceNode) Type() string { return "recall" }
+
+func (n *resourceNode) Execute(ctx *Context) error {
+	if n.entered != nil {
+		close(n.entered)
+	}
+	if n.gate != nil {
+		<-n.gate
+	}
+	return nil
+}
+
+func (n *resourceNode) Init(ctx context.Context) error {
+	time.Sleep(n.initWait)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.initialized = true
+	return n.initErr
+}
+
+func (n *resourceNode) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	return nil
+}
+
+func (n *resourceNode) state() (initialized, closed bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.initialized, n.closed
+}
+
+// lifecycleRegistry 注册 resource 节点类型，并记录创建的所有实例
+type lifecycleRegistry struct {
+	*Registry
+	mu    sync.Mutex
+	nodes []*resourceNode
+	gate  chan struct{}
+}
+
+func newLifecycleRegistry() *lifecycleRegistry {
+	lr := &lifecycleRegistry{Registry: newTestRegistry()}
+	lr.Register("resource", func(cfg NodeConfig) (Node, error) {
+		n := &resourceNode{name: cfg.Name, gate: lr.gate}
+		if msg, ok := cfg.Config["init_error"].(string); ok {
+			n.initErr = errors.New(msg)
+		}
+		if ms, ok := cfg.Config["init_wait_ms"].(float64); ok {
+			n.initWait = time.Duration(ms) * time.Millisecond
+		}
+		lr.mu.Lock()
+		lr.nodes = append(lr.nodes, n)
+		lr.mu.Unlock()
+		return n, nil
+	})
+	return lr
+}
+
+func (lr *lifecycleRegistry) created() []*resourceNode {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return append([]*resourceNode(nil), lr.nodes...)
+}
+
+func TestNodeLifecycle(t *testing.T) {
+	// 包装在超时和并行节点中的节点同样会被初始化和关闭
+	config := `{"pipelines": {"music": {"nodes": [
+		{"name": "group", "type": "parallel", "nodes": [{"name": "index", "type": "resource", "timeout_ms": 1000}]}
+	]}}}`
+	path := writeConfig(t, config)
+	lr := newLifecycleRegistry()
+	lr.gate = make(chan struct{})
+	engine, err := NewEngine(path, lr.Registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	first := lr.created()[0]
+	if initialized, closed := first.state(); !initialized || closed {
+		t.Fatalf("expected node initialized and open, got initialized=%v closed=%v", initialized, closed)
+	}
+
+	// 执行中的请求持有旧配置，热更新后旧节点在请求结束时才关闭
+	done := make(chan error)
+	go func() {
+		done <- engine.Run(NewContext(context.Background(), "u1", &model.User{ID: "u1"}), "music")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := engine.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, closed := first.state(); closed {
+		t.Fatal("old node closed while a request is still running")
+	}
+	close(lr.gate)
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, closed := first.state(); !closed {
+		t.Error("old node not closed after the request finished")
+	}
+
+	second := lr.created()[1]
+	if err := engine.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, closed := second.state(); !closed {
+		t.Error("node not closed on engine shutdown")
+	}
+	if err := engine.Run(NewContext(context.Background(), "u1", &model.User{ID: "u1"}), "music"); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("expected ErrEngineClosed, got %v", err)
+	}
+	if r := engine.Readiness(); r.Ready {
+		t.Error("closed engine reported ready")
+	}
+}
+
+func TestRunAfterCloseIsRejected(t *testing.T) {
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [{"name": "index", "type": "resource"}]}}}`)
+	lr := newLifecycleRegistry()
+	lr.gate = make(chan struct{})
+	engine, err := NewEngine(path, lr.Registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// 执行中的请求让 Close 等待，此时新的请求应当立即被拒绝
+	running := make(chan error)
+	go func() {
+		running <- engine.Run(NewContext(context.Background(), "u1", &model.User{ID: "u1"}), "music")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	closed := make(chan error)
+	go func() { closed <- engine.Close(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	rejected := make(chan error, 1)
+	go func() {
+		rejected <- engine.Run(NewContext(context.Background(), "u2", &model.User{ID: "u2"}), "music")
+	}()
+	select {
+	case err := <-rejected:
+		if !errors.Is(err, ErrEngineClosed) {
+			t.Errorf("expected ErrEngineClosed while closing, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Run accepted after Close")
+	}
+	close(lr.gate)
+	if err := <-running; err != nil {
+		t.Errorf("in-flight Run failed: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestParallelStragglerKeepsPipelinesOpen(t *testing.T) {
+	// first:1 不等待被取消的子节点，但它们结束前旧配置中的节点不会被关闭
+	config := `{"pipelines": {"music": {"nodes": [
+		{"name": "group", "type": "parallel", "config": {"policy": "first:1"}, "nodes": [
+			{"name": "fast", "type": "after"},
+			{"name": "slow", "type": "resource"}
+		]}
+	]}}}`
+	lr := newLifecycleRegistry()
+	lr.gate = make(chan struct{})
+	entered := make(chan struct{})
+	lr.Register("after", func(cfg NodeConfig) (Node, error) {
+		return &afterNode{stubNode{name: cfg.Name, items: []string{"a"}}, entered}, nil
+	})
+	engine, err := NewEngine(writeConfig(t, config), lr.Registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	slow := lr.created()[0]
+	slow.entered = entered
+
+	if err := engine.Run(NewContext(context.Background(), "u1", &model.User{ID: "u1"}), "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if err := engine.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, closed := slow.state(); closed {
+		t.Fatal("node closed while a canceled child is still executing it")
+	}
+
+	close(lr.gate)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, closed := slow.state(); closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("old node not closed after the canceled child finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNodeInitFailure(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		want   string
+	}{
+		"error": {
+			`{"pipelines": {"music": {"nodes": [{"name": "index", "type": "resource", "config": {"init_error": "index missing"}}]}}}`,
+			"pipelines.music: node 'index' init failed: index missing",
+		},
+		"timeout": {
+			`{"init_timeout_ms": 20, "pipelines": {"music": {"nodes": [{"name": "index", "type": "resource", "config": {"init_wait_ms": 100}}]}}}`,
+			"pipelines.music: node 'index' init did not finish within 20ms",
+		},
+	}
+	for name, tc := range cases {
+		lr := newLifecycleRegistry()
+		_, err := NewEngine(writeConfig(t, tc.config), lr.Registry)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+			continue
+		}
+		// 加载失败的配置中的节点在 Init 返回后被关闭
+		node := lr.created()[0]
+		deadline := time.Now().Add(time.Second)
+		for _, closed := node.state(); !closed && time.Now().Before(deadline); _, closed = node.state() {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if _, closed := node.state(); !closed {
+			t.Errorf("%s: node not closed after failed init", name)
+		}
+	}
+}
+
+func TestReloadKeepsOldPipelinesWhenInitFails(t *testing.T) {
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [{"name": "index", "type": "resource"}]}}}`)
+	lr := newLifecycleRegistry()
+	engine, err := NewEngine(path, lr.Registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close(context.Background())
+
+	bad := `{"pipelines": {"music": {"nodes": [{"name": "index", "type": "resource", "config": {"init_error": "boom"}}]}}}`
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reload(); err == nil {
+		t.Fatal("expected reload to fail")
+	}
+	if _, closed := lr.created()[0].state(); closed {
+		t.Error("current node closed by a failed reload")
+	}
+	if err := engine.Run(NewContext(context.Background(), "u1", &model.User{ID: "u1"}), "music"); err != nil {
+		t.Errorf("expected previous pipelines to keep serving, got %v", err)
+	}
+}
+
+func TestReadinessDuringReload(t *testing.T) {
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [{"name": "index", "type": "resource"}]}}}`)
+	engine, err := NewEngine(path, newLifecycleRegistry().Registry)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close(context.Background())
+
+	slow := `{"pipelines": {"music": {"nodes": [{"name": "warm", "type": "resource", "config": {"init_wait_ms": 300}}]}}}`
+	if err := os.WriteFile(path, []byte(slow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- engine.Reload() }()
+
+	// Init 执行期间 Readiness 立即返回，并列出尚未完成的节点
+	var pending []WarmupStat
+	for deadline := time.Now().Add(250 * time.Millisecond); len(pending) == 0 && time.Now().Before(deadline); {
+		start := time.Now()
+		r := engine.Readiness()
+		if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+			t.Fatalf("Readiness blocked for %v during reload", elapsed)
+		}
+		if !r.Ready {
+			t.Fatalf("expected engine to stay ready during reload, got %+v", r)
+		}
+		pending = r.Pending
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(pending) != 1 || pending[0].Node != "warm" || pending[0].Done {
+		t.Errorf("expected warm to be pending, got %+v", pending)
+	}
+
+	if err := <-reloaded; err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if r := engine.Readiness(); len(r.Pending) != 0 || len(r.Warmups) != 1 || r.Warmups[0].Node != "warm" {
+		t.Errorf("unexpected readiness after reload: %+v", r)
+	}
+}
+
+func TestValidateConfigSkipsInit(t *testing.T) {
+	// Init 会失败的节点不影响校验，节点被构建和关闭但不会被 Init
+	path := writeConfig(t, `{"pipelines": {"music": {"nodes": [
+		{"name": "index", "type": "resource", "config": {"init_error": "dependency down"}}
+	]}}}`)
+	lr := newLifecycleRegistry()
+	if _, err := ValidateConfig(path, lr.Registry); err != nil {
+		t.Fatalf("ValidateConfig failed: %v", err)
+	}
+	nodes := lr.created()
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(nodes))
+	}
+	if initialized, closed := nodes[0].state(); initialized || !closed {
+		t.Errorf("expected node to be closed without Init, got initialized=%v closed=%v", initialized, closed)
+	}
+
+	path = writeConfig(t, `{"pipelines": {"music": {"nodes": [{"name": "a", "type": "nope"}]}}}`)
+	if _, err := ValidateConfig(path, lr.Registry); err == nil || !strings.Contains(err.Error(), "unknown node type: nope") {
+		t.Errorf("expected unknown node type error, got %v", err)
+	}
+}
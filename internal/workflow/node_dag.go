@@ -0,0 +1,228 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"recommend_engine/internal/model"
+)
+
+// dagVertex 是 DAG 中的一个节点及其依赖
+type dagVertex struct {
+	node Node
+	deps []int // 依赖节点在 vertices 中的下标，按 depends_on 声明顺序
+	sink bool  // 没有其他节点依赖它，其输出会合并为 DAG 的最终结果
+}
+
+// DAGNode 按依赖关系调度一组节点
+// 每个节点运行在独立的分支 Context 上：
+//   - 没有依赖的节点以 DAG 的输入候选集作为初始候选集
+//   - 有依赖的节点以所有依赖节点输出的拼接作为初始候选集 (汇合点)
+//
+// 相互独立的分支并发执行，所有末端节点的输出按声明顺序拼接后写回主候选集。
+type DAGNode struct {
+	nodeName string
+	vertices []*dagVertex
+}
+
+// NewDAGNode 根据节点配置中的 depends_on 构建 DAG
+// 构建时会检查节点重名、依赖缺失以及环
+func NewDAGNode(name string, cfgs []NodeConfig, registry *Registry) (*DAGNode, error) {
+	index := make(map[string]int, len(cfgs))
+	for i, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, withPath(fmt.Sprintf("nodes[%d].name", i), fmt.Errorf("nodes in a dag must have a name"))
+		}
+		if _, dup := index[cfg.Name]; dup {
+			return nil, withPath(fmt.Sprintf("nodes[%d].name", i), fmt.Errorf("duplicate node name '%s'", cfg.Name))
+		}
+		index[cfg.Name] = i
+	}
+
+	vertices := make([]*dagVertex, len(cfgs))
+	depended := make([]bool, len(cfgs))
+	for i, cfg := range cfgs {
+		v := &dagVertex{}
+		for _, dep := range cfg.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, withPath(fmt.Sprintf("nodes[%d].depends_on", i), fmt.Errorf("node '%s' depends on unknown node '%s'", cfg.Name, dep))
+			}
+			if j == i {
+				return nil, withPath(fmt.Sprintf("nodes[%d].depends_on", i), fmt.Errorf("node '%s' depends on itself", cfg.Name))
+			}
+			v.deps = append(v.deps, j)
+			depended[j] = true
+		}
+		vertices[i] = v
+	}
+
+	if cycle := findCycle(cfgs, vertices); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	var errs ConfigErrors
+	for i, cfg := range cfgs {
+		node, err := registry.CreateNode(cfg)
+		if err != nil {
+			errs = errs.append(withPath(fmt.Sprintf("nodes[%d]", i), err))
+			continue
+		}
+		vertices[i].node = node
+		vertices[i].sink = !depended[i]
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &DAGNode{
+		nodeName: name,
+		vertices: vertices,
+	}, nil
+}
+
+// findCycle 使用 DFS 检测环，返回环上的节点名 (首尾相同)，无环时返回 nil
+func findCycle(cfgs []NodeConfig, vertices []*dagVertex) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	color := make([]int, len(vertices))
+	var stack []int
+
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		color[i] = visiting
+		stack = append(stack, i)
+		for _, d := range vertices[i].deps {
+			switch color[d] {
+			case visiting:
+				// 从栈中找到环的起点
+				var cycle []string
+				for k := len(stack) - 1; k >= 0; k-- {
+					cycle = append([]string{cfgs[stack[k]].Name}, cycle...)
+					if stack[k] == d {
+						break
+					}
+				}
+				return append(cycle, cfgs[d].Name)
+			case unvisited:
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[i] = done
+		return nil
+	}
+
+	for i := range vertices {
+		if color[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+func (n *DAGNode) Name() string {
+	return n.nodeName
+}
+
+func (n *DAGNode) Type() string {
+	return "dag"
+}
+
+// Children 返回 DAG 中的所有节点，按声明顺序
+func (n *DAGNode) Children() []Node {
+	children := make([]Node, len(n.vertices))
+	for i, v := range n.vertices {
+		children[i] = v.node
+	}
+	return children
+}
+
+// Execute 按依赖关系并发执行所有节点
+// 任意节点失败都会取消尚未完成的节点，并返回第一个错误
+func (n *DAGNode) Execute(ctx *Context) error {
+	ctx.AddLog(fmt.Sprintf("Start DAGNode: %s (%d nodes)", n.nodeName, len(n.vertices)))
+
+	runCtx, cancel := context.WithCancel(ctx.Ctx)
+	defer cancel()
+	dagCtx := ctx.WithContext(runCtx)
+	input := ctx.GetCandidates()
+
+	outputs := make([][]*model.Item, len(n.vertices))
+	done := make([]chan struct{}, len(n.vertices))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i, v := range n.vertices {
+		wg.Add(1)
+		go func(i int, v *dagVertex) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, d := range v.deps {
+				<-done[d]
+			}
+			// 有节点失败或上游取消时，跳过剩余节点
+			if runCtx.Err() != nil {
+				return
+			}
+
+			var items []*model.Item
+			if len(v.deps) == 0 {
+				items = input
+			} else {
+				for _, d := range v.deps {
+					items = append(items, outputs[d]...)
+				}
+			}
+
+			branch := dagCtx.Fork(items)
+			ctx.AddLog(fmt.Sprintf("  -> Start dag node: %s", v.node.Name()))
+			if err := runNode(branch, v.node); err != nil {
+				ctx.AddLog(fmt.Sprintf("  -> Node %s failed: %v", v.node.Name(), err))
+				once.Do(func() {
+					firstErr = fmt.Errorf("dag node %s: %w", v.node.Name(), err)
+					cancel()
+				})
+				return
+			}
+			outputs[i] = branch.GetCandidates()
+			ctx.AddLog(fmt.Sprintf("  -> Node %s completed with %d candidates", v.node.Name(), len(outputs[i])))
+		}(i, v)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Ctx.Err(); err != nil {
+		return err
+	}
+
+	// 汇合所有末端节点的输出
+	var result []*model.Item
+	for i, v := range n.vertices {
+		if v.sink {
+			result = append(result, outputs[i]...)
+		}
+	}
+	ctx.UpdateCandidates(result)
+	ctx.AddLog(fmt.Sprintf("End DAGNode: %s, Result count: %d", n.nodeName, len(result)))
+
+	return nil
+}
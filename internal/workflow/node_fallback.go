@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FallbackNode 是一个组合节点，按顺序尝试子节点，直到有一个成功
+// 典型用法：doubao 召回失败时降级到 xinhuo，再降级到静态热门列表
+type FallbackNode struct {
+	nodeName string
+	children []Node
+}
+
+// NewFallbackNode 创建一个新的降级节点
+func NewFallbackNode(name string, children []Node) *FallbackNode {
+	return &FallbackNode{
+		nodeName: name,
+		children: children,
+	}
+}
+
+func (n *FallbackNode) Name() string {
+	return n.nodeName
+}
+
+func (n *FallbackNode) Type() string {
+	return "fallback"
+}
+
+// Children 返回按优先级排列的子节点
+func (n *FallbackNode) Children() []Node {
+	return n.children
+}
+
+// Execute 依次执行子节点，第一个成功的子节点即为结果
+// 子节点应当只在成功时写入 Context；上游 ctx 被取消时不再尝试后续子节点
+func (n *FallbackNode) Execute(ctx *Context) error {
+	var errs []string
+	for i, child := range n.children {
+		err := runNode(ctx, child)
+		if err == nil {
+			ctx.span.SetAttribute("selected", child.Name())
+			if i > 0 {
+				ctx.AddLog(fmt.Sprintf("FallbackNode %s succeeded with fallback #%d: %s", n.nodeName, i, child.Name()))
+			}
+			return nil
+		}
+
+		errs = append(errs, fmt.Sprintf("node %s: %v", child.Name(), err))
+		if ctx.Ctx.Err() != nil {
+			return fmt.Errorf("fallback node %s aborted: %w", n.nodeName, err)
+		}
+		ctx.AddLog(fmt.Sprintf("FallbackNode %s: %s failed: %v, trying next", n.nodeName, child.Name(), err))
+	}
+	return fmt.Errorf("all fallback nodes failed: %s", strings.Join(errs, "; "))
+}
@@ -1,23 +1,91 @@
 package workflow
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
+	"time"
 )
 
+// 并行节点的成功策略
+const (
+	PolicyAny      = "any"      // 任意一个子节点成功即成功 (默认，Best Effort)
+	PolicyAll      = "all"      // 任意一个子节点失败即失败
+	PolicyQuorum   = "quorum"   // quorum:N，至少 N 个子节点成功
+	PolicyFirst    = "first"    // first:N，N 个子节点成功后取消其余子节点
+	PolicyDeadline = "deadline" // deadline:<ms>，到达软超时后取消未完成的子节点，返回已完成的结果
+)
+
+// ParallelPolicy 描述并行节点何时结束以及如何判定成功
+type ParallelPolicy struct {
+	Kind     string
+	N        int           // quorum / first 所需的成功数
+	Deadline time.Duration // deadline 策略的软超时
+}
+
+// ParseParallelPolicy 解析形如 "all"、"quorum:2"、"first:2"、"deadline:1500" 的策略字符串
+// 空字符串表示默认的 "any" 策略
+func ParseParallelPolicy(s string, childCount int) (ParallelPolicy, error) {
+	if s == "" {
+		return ParallelPolicy{Kind: PolicyAny}, nil
+	}
+
+	kind, arg := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+
+	switch kind {
+	case PolicyAny, PolicyAll:
+		if arg != "" {
+			return ParallelPolicy{}, fmt.Errorf("policy '%s' takes no argument", kind)
+		}
+		return ParallelPolicy{Kind: kind}, nil
+	case PolicyQuorum, PolicyFirst:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return ParallelPolicy{}, fmt.Errorf("policy '%s' requires an integer argument, e.g. %s:2", kind, kind)
+		}
+		if n < 1 || n > childCount {
+			return ParallelPolicy{}, fmt.Errorf("policy '%s' argument must be between 1 and %d, got %d", kind, childCount, n)
+		}
+		return ParallelPolicy{Kind: kind, N: n}, nil
+	case PolicyDeadline:
+		ms, err := strconv.Atoi(arg)
+		if err != nil || ms <= 0 {
+			return ParallelPolicy{}, fmt.Errorf("policy 'deadline' requires a positive millisecond argument, e.g. deadline:1500")
+		}
+		return ParallelPolicy{Kind: kind, Deadline: time.Duration(ms) * time.Millisecond}, nil
+	default:
+		return ParallelPolicy{}, fmt.Errorf("unknown parallel policy: %s", s)
+	}
+}
+
+func (p ParallelPolicy) String() string {
+	switch p.Kind {
+	case PolicyQuorum, PolicyFirst:
+		return fmt.Sprintf("%s:%d", p.Kind, p.N)
+	case PolicyDeadline:
+		return fmt.Sprintf("%s:%d", p.Kind, p.Deadline.Milliseconds())
+	default:
+		return p.Kind
+	}
+}
+
 // ParallelNode 是一个组合节点，用于并发执行多个子节点
 type ParallelNode struct {
 	nodeName string
 	children []Node
+	policy   ParallelPolicy
 }
 
 // NewParallelNode 创建一个新的并行节点
-func NewParallelNode(name string, children []Node) *ParallelNode {
+func NewParallelNode(name string, children []Node, policy ParallelPolicy) *ParallelNode {
 	return &ParallelNode{
 		nodeName: name,
 		children: children,
+		policy:   policy,
 	}
 }
 
@@ -29,58 +97,132 @@ func (n *ParallelNode) Type() string {
 	return "parallel"
 }
 
-// Execute 并发执行所有子节点
-// 采用 "Best Effort" 策略：只要有一个子节点成功，就不视为整个节点失败。
-// 只有当所有子节点都失败时，才返回错误。
+// Children 返回并发执行的子节点
+func (n *ParallelNode) Children() []Node {
+	return n.children
+}
+
+// childResult 是单个子节点的执行结果
+type childResult struct {
+	node   Node
+	branch *Context // 子节点独立的候选集
+	span   *Span    // 子节点 Span 的临时父节点，按时返回的子节点才会挂到本节点的 Span 下
+	err    error
+}
+
+// Execute 并发执行所有子节点，并按策略判定结果
+// 每个子节点在独立的分支上执行，返回后按完成顺序把新增、移除的条目合并回候选集，
+// 写入的召回结果、日志和快照也在此时合并，因此 debug 快照中只包含子节点自身的修改。
+// first / deadline 策略提前结束或上游被取消时会取消其余子节点并立即返回，不等待它们退出：
+// 未完成的子节点在后台继续执行直到返回，其所有写入都被丢弃，也不计为失败。
+// 后台的子节点持有流程集合的引用，结束前不会关闭其中的节点。
 func (n *ParallelNode) Execute(ctx *Context) error {
-	ctx.AddLog(fmt.Sprintf("Start ParallelNode: %s", n.nodeName))
+	ctx.span.SetAttribute("policy", n.policy.String())
+	ctx.AddLog(fmt.Sprintf("Start ParallelNode: %s (policy: %s)", n.nodeName, n.policy))
 
-	var wg sync.WaitGroup
-	var successCount int32
-	var errors []string
-	var mu sync.Mutex // 保护 errors 切片
+	runCtx, cancel := context.WithCancel(ctx.Ctx)
+	defer cancel()
+	childCtx := ctx.WithContext(runCtx)
+	input := ctx.GetCandidates()
 
+	// 缓冲区可以容纳所有结果，提前返回后仍在执行的子节点不会阻塞
+	results := make(chan childResult, len(n.children))
 	for _, child := range n.children {
-		wg.Add(1)
+		if ctx.pipelines != nil {
+			ctx.pipelines.retain()
+		}
+		ctx.AddLog(fmt.Sprintf("  -> Start child node: %s", child.Name()))
 		go func(node Node) {
-			defer wg.Done()
-			
-			// 可以在这里增加 recover 防止 panic 导致 crash
-			defer func() {
-				if r := recover(); r != nil {
-					mu.Lock()
-					errors = append(errors, fmt.Sprintf("node %s panic: %v", node.Name(), r))
-					mu.Unlock()
-				}
-			}()
-
-			ctx.AddLog(fmt.Sprintf("  -> Start child node: %s", node.Name()))
-			if err := node.Execute(ctx); err != nil {
-				ctx.AddLog(fmt.Sprintf("  -> Node %s failed: %v", node.Name(), err))
-				mu.Lock()
-				errors = append(errors, fmt.Sprintf("node %s: %v", node.Name(), err))
-				mu.Unlock()
-			} else {
-				atomic.AddInt32(&successCount, 1)
-				ctx.AddLog(fmt.Sprintf("  -> Node %s completed", node.Name()))
+			if ctx.pipelines != nil {
+				defer ctx.pipelines.release()
 			}
+			// panic 由拦截器链中的 RecoveryInterceptor 转换为错误
+			branch := childCtx.forkChild(input)
+			holder := &Span{}
+			err := runNode(branch.withSpan(holder), node)
+			results <- childResult{node: node, branch: branch, span: holder, err: err}
 		}(child)
 	}
 
-	wg.Wait()
+	var deadline <-chan time.Time
+	if n.policy.Kind == PolicyDeadline {
+		timer := time.NewTimer(n.policy.Deadline)
+		defer timer.Stop()
+		deadline = timer.C
+	}
 
-	// 决策逻辑：
-	// 1. 如果有至少一个成功，则认为整体成功（Partial Success）
-	// 2. 如果所有都失败，则返回聚合错误
-	if successCount == 0 && len(errors) > 0 {
-		return fmt.Errorf("all parallel nodes failed: %s", strings.Join(errors, "; "))
+	successCount := 0
+	var errs []string
+	stopped := false // 是否已由本节点按策略结束
+
+	received := 0
+	for received < len(n.children) && !stopped {
+		select {
+		case r := <-results:
+			received++
+			ctx.mergeBranch(input, r.branch)
+			ctx.adopt(r.branch)
+			ctx.span.adopt(r.span)
+			if r.err == nil {
+				successCount++
+				ctx.AddLog(fmt.Sprintf("  -> Node %s completed", r.node.Name()))
+			} else {
+				errs = append(errs, fmt.Sprintf("node %s: %v", r.node.Name(), r.err))
+				ctx.AddLog(fmt.Sprintf("  -> Node %s failed: %v", r.node.Name(), r.err))
+			}
+
+			if n.policy.Kind == PolicyFirst && successCount >= n.policy.N {
+				stopped = true
+			}
+		case <-deadline:
+			stopped = true
+			ctx.AddLog(fmt.Sprintf("ParallelNode %s reached soft deadline", n.nodeName))
+		case <-ctx.Ctx.Done():
+			// 上游被取消时不按策略判定，已完成的部分结果也不再继续向下游传递
+			return &CanceledError{Node: n.nodeName, Err: ctx.Ctx.Err()}
+		}
 	}
 
-	if len(errors) > 0 {
-		ctx.AddLog(fmt.Sprintf("ParallelNode completed with %d errors (ignored due to partial success): %v", len(errors), errors))
+	canceledCount := len(n.children) - received
+	if canceledCount > 0 {
+		ctx.AddLog(fmt.Sprintf("  -> Canceled %d unfinished children by policy %s", canceledCount, n.policy))
+	}
+
+	if err := n.decide(successCount, canceledCount, errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		ctx.AddLog(fmt.Sprintf("ParallelNode completed with %d errors (ignored due to policy %s): %v", len(errs), n.policy, errs))
 	} else {
-		ctx.AddLog(fmt.Sprintf("End ParallelNode: %s (All success)", n.nodeName))
+		ctx.AddLog(fmt.Sprintf("End ParallelNode: %s (%d success, %d canceled)", n.nodeName, successCount, canceledCount))
+	}
+
+	return nil
+}
+
+// decide 根据策略判定整体是否成功
+func (n *ParallelNode) decide(successCount, canceledCount int, errs []string) error {
+	switch n.policy.Kind {
+	case PolicyAll:
+		if len(errs) > 0 {
+			return fmt.Errorf("parallel node %s failed (policy all): %s", n.nodeName, strings.Join(errs, "; "))
+		}
+	case PolicyQuorum, PolicyFirst:
+		if successCount < n.policy.N {
+			return fmt.Errorf("parallel node %s: only %d of %d required children succeeded (policy %s): %s",
+				n.nodeName, successCount, n.policy.N, n.policy, strings.Join(errs, "; "))
+		}
+	case PolicyDeadline:
+		if successCount == 0 && (len(errs) > 0 || canceledCount > 0) {
+			return fmt.Errorf("no parallel node finished before deadline (policy %s): %s", n.policy, strings.Join(errs, "; "))
+		}
+	default:
+		// 如果有至少一个成功，则认为整体成功（Partial Success）
+		// 如果所有都失败，则返回聚合错误
+		if successCount == 0 && len(errs) > 0 {
+			return fmt.Errorf("all parallel nodes failed: %s", strings.Join(errs, "; "))
+		}
 	}
-	
 	return nil
 }
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+)
+
+func TestParseParallelPolicy(t *testing.T) {
+	valid := map[string]string{
+		"":              "any",
+		"all":           "all",
+		"quorum:2":      "quorum:2",
+		"first:1":       "first:1",
+		"deadline:1500": "deadline:1500",
+	}
+	for in, want := range valid {
+		p, err := ParseParallelPolicy(in, 4)
+		if err != nil {
+			t.Errorf("ParseParallelPolicy(%q) failed: %v", in, err)
+			continue
+		}
+		if p.String() != want {
+			t.Errorf("ParseParallelPolicy(%q) = %s, want %s", in, p, want)
+		}
+	}
+
+	for _, in := range []string{"quorum", "first:0", "first:5", "deadline", "deadline:-1", "all:2", "fastest"} {
+		if _, err := ParseParallelPolicy(in, 4); err == nil {
+			t.Errorf("ParseParallelPolicy(%q) expected error", in)
+		}
+	}
+}
+
+func runParallel(t *testing.T, policy string, children ...Node) (*Context, error) {
+	t.Helper()
+	p, err := ParseParallelPolicy(policy, len(children))
+	if err != nil {
+		t.Fatalf("invalid policy %q: %v", policy, err)
+	}
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	return ctx, NewParallelNode("group", children, p).Execute(ctx)
+}
+
+func TestParallelPolicies(t *testing.T) {
+	ok1 := &stubNode{name: "ok1", items: []string{"a"}}
+	ok2 := &stubNode{name: "ok2", items: []string{"b"}}
+	bad := &stubNode{name: "bad", err: errors.New("boom")}
+	hang := &stubNode{name: "hang", block: true}
+
+	if _, err := runParallel(t, "", ok1, bad); err != nil {
+		t.Errorf("any: expected partial success, got %v", err)
+	}
+	if _, err := runParallel(t, "all", ok1, bad); err == nil {
+		t.Error("all: expected failure when a child fails")
+	}
+	if _, err := runParallel(t, "quorum:2", ok1, ok2, bad); err != nil {
+		t.Errorf("quorum:2: expected success, got %v", err)
+	}
+	if _, err := runParallel(t, "quorum:2", ok1, bad); err == nil {
+		t.Error("quorum:2: expected failure with a single success")
+	}
+
+	start := time.Now()
+	ctx, err := runParallel(t, "first:2", ok1, ok2, hang)
+	if err != nil {
+		t.Errorf("first:2: expected success, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("first:2: hanging child was not canceled")
+	}
+	if got := len(ctx.GetCandidates()); got != 2 {
+		t.Errorf("first:2: expected 2 candidates, got %d", got)
+	}
+
+	start = time.Now()
+	if _, err := runParallel(t, "deadline:30", ok1, hang); err != nil {
+		t.Errorf("deadline: expected success, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("deadline: hanging child was not canceled")
+	}
+	if _, err := runParallel(t, "deadline:30", hang); err == nil {
+		t.Error("deadline: expected failure when nothing finished")
+	}
+}
+
+// stubbornNode 忽略 ctx 的取消，等待 release 关闭 (最多 2 秒) 后才写入召回结果
+type stubbornNode struct {
+	stubNode
+	release chan struct{}
+	started chan struct{}
+	done    chan struct{}
+}
+
+func (n *stubbornNode) Execute(ctx *Context) error {
+	defer close(n.done)
+	close(n.started)
+	select {
+	case <-n.release:
+	case <-time.After(2 * time.Second):
+	}
+	ctx.AddLog(n.name + " finished")
+	return n.stubNode.Execute(ctx)
+}
+
+// afterNode 等待 after 关闭后再执行，用于保证兄弟节点已经开始执行
+type afterNode struct {
+	stubNode
+	after chan struct{}
+}
+
+func (n *afterNode) Execute(ctx *Context) error {
+	<-n.after
+	return n.stubNode.Execute(ctx)
+}
+
+func TestParallelReturnsWithoutWaitingForCanceledChildren(t *testing.T) {
+	for _, policy := range []string{"first:1", "deadline:30"} {
+		slow := &stubbornNode{stubNode: stubNode{name: "slow", items: []string{"late"}},
+			release: make(chan struct{}), started: make(chan struct{}), done: make(chan struct{})}
+		fast := &afterNode{stubNode{name: "fast", items: []string{"a"}}, slow.started}
+
+		start := time.Now()
+		ctx, err := runParallel(t, policy, fast, slow)
+		if err != nil {
+			t.Errorf("%s: expected success, got %v", policy, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: waited %v for a child that ignores ctx", policy, elapsed)
+		}
+
+		// 未完成的子节点在后台结束，其修改不会影响已经返回的候选集
+		close(slow.release)
+		<-slow.done
+		if got := candidateNames(ctx); len(got) != 1 || got[0] != "a" {
+			t.Errorf("%s: expected [a], got %v", policy, got)
+		}
+		if _, ok := ctx.GetRecallResults()["slow"]; ok {
+			t.Errorf("%s: recall result of a canceled child was kept", policy)
+		}
+		for _, msg := range ctx.TraceLog {
+			if msg == "slow finished" {
+				t.Errorf("%s: log of a canceled child was kept", policy)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SubPipelineNode 将另一个命名 Pipeline 作为一个步骤执行
+// 子流程与当前流程共享同一个 Context，常用于在多个场景间复用过滤、排序等公共阶段
+type SubPipelineNode struct {
+	nodeName string
+	target   string
+}
+
+// NewSubPipelineNode 创建一个新的子流程节点
+func NewSubPipelineNode(name string, target string) *SubPipelineNode {
+	return &SubPipelineNode{
+		nodeName: name,
+		target:   target,
+	}
+}
+
+func (n *SubPipelineNode) Name() string {
+	return n.nodeName
+}
+
+func (n *SubPipelineNode) Type() string {
+	return "pipeline"
+}
+
+// Target 返回引用的 Pipeline 名称
+func (n *SubPipelineNode) Target() string {
+	return n.target
+}
+
+// Execute 在当前 Context 上执行引用的 Pipeline
+// 使用本次请求开始时的流程集合快照，与外层流程保持一致
+func (n *SubPipelineNode) Execute(ctx *Context) error {
+	if ctx.pipelines == nil {
+		return fmt.Errorf("pipeline node %s must be executed by an Engine", n.nodeName)
+	}
+
+	ctx.span.SetAttribute("pipeline", n.target)
+	ctx.AddLog(fmt.Sprintf("Enter sub-pipeline: %s (node %s)", n.target, n.nodeName))
+	if err := ctx.pipelines.run(ctx, n.target); err != nil {
+		return fmt.Errorf("sub-pipeline %s: %w", n.target, err)
+	}
+	ctx.AddLog(fmt.Sprintf("Exit sub-pipeline: %s", n.target))
+	return nil
+}
+
+// pipelineRefs 收集节点配置树中所有 pipeline 节点引用的 Pipeline 名称
+func pipelineRefs(cfgs []NodeConfig) []string {
+	var refs []string
+	for _, cfg := range cfgs {
+		if cfg.Type == "pipeline" {
+			if target, ok := cfg.Config["pipeline"].(string); ok && target != "" {
+				refs = append(refs, target)
+			}
+		}
+		refs = append(refs, pipelineRefs(cfg.Nodes)...)
+		refs = append(refs, pipelineRefs(cfg.Default)...)
+		for _, c := range cfg.Cases {
+			refs = append(refs, pipelineRefs(c.Nodes)...)
+		}
+	}
+	return refs
+}
+
+// checkPipelineRefs 检查子流程引用是否存在以及是否存在递归引用
+func checkPipelineRefs(cfg GlobalConfig) error {
+	// 按名称排序，保证报错信息稳定
+	scenes := make([]string, 0, len(cfg.Pipelines))
+	for scene := range cfg.Pipelines {
+		scenes = append(scenes, scene)
+	}
+	sort.Strings(scenes)
+
+	refs := make(map[string][]string, len(cfg.Pipelines))
+	for _, scene := range scenes {
+		for _, nodes := range cfg.Pipelines[scene].NodeLists() {
+			refs[scene] = append(refs[scene], pipelineRefs(nodes)...)
+		}
+		for _, target := range refs[scene] {
+			if _, ok := cfg.Pipelines[target]; !ok {
+				return fmt.Errorf("pipeline '%s' includes unknown pipeline '%s'", scene, target)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(scenes))
+	var path []string
+
+	var visit func(scene string) error
+	visit = func(scene string) error {
+		state[scene] = visiting
+		path = append(path, scene)
+		for _, target := range refs[scene] {
+			switch state[target] {
+			case visiting:
+				for i, s := range path {
+					if s == target {
+						cycle := append(append([]string{}, path[i:]...), target)
+						return fmt.Errorf("recursive pipeline include: %s", strings.Join(cycle, " -> "))
+					}
+				}
+			case unvisited:
+				if err := visit(target); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[scene] = done
+		return nil
+	}
+
+	for _, scene := range scenes {
+		if state[scene] == unvisited {
+			if err := visit(scene); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
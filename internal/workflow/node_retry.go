@@ -0,0 +1,155 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryConfig 节点级重试配置
+type RetryConfig struct {
+	MaxAttempts  int      `json:"max_attempts" yaml:"max_attempts"`                         // 最大执行次数 (包含首次执行)
+	BackoffMs    int      `json:"backoff_ms,omitempty" yaml:"backoff_ms,omitempty"`         // 首次重试前的等待时间
+	MaxBackoffMs int      `json:"max_backoff_ms,omitempty" yaml:"max_backoff_ms,omitempty"` // 等待时间上限，0 表示不限制
+	Multiplier   float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`         // 每次重试等待时间的放大倍数，默认 2
+	RetryOn      []string `json:"retry_on,omitempty" yaml:"retry_on,omitempty"`             // 可重试的错误类别，为空表示所有错误
+}
+
+// retry_on 中可以使用的错误类别
+// 错误通过 errors.Is / errors.As 分类，不检查错误信息的文本
+const (
+	RetryOnTimeout     = "timeout"      // 节点的 timeout_ms 到期，或外部调用超时
+	RetryOnNetwork     = "network"      // 连接失败等网络错误 (net.Error)
+	RetryOnServerError = "server_error" // 外部服务返回 5xx
+	RetryOnRateLimited = "rate_limited" // 外部服务返回 429
+)
+
+var retryOnKinds = []string{RetryOnTimeout, RetryOnNetwork, RetryOnServerError, RetryOnRateLimited}
+
+// HTTPStatusError 由携带外部服务 HTTP 状态码的错误实现 (如 llm.APIError)，
+// retry_on 中的 server_error / rate_limited 通过 errors.As 查找该接口
+type HTTPStatusError interface {
+	error
+	HTTPStatus() int
+}
+
+// validate 检查重试配置并填充默认值
+func (c *RetryConfig) validate() error {
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1, got %d", c.MaxAttempts)
+	}
+	if c.BackoffMs < 0 || c.MaxBackoffMs < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = 2
+	}
+	if c.Multiplier < 1 {
+		return fmt.Errorf("retry.multiplier must be at least 1, got %v", c.Multiplier)
+	}
+	for _, kind := range c.RetryOn {
+		if !containsString(retryOnKinds, kind) {
+			return fmt.Errorf("retry.retry_on: unknown error kind '%s', expected one of [%s]", kind, strings.Join(retryOnKinds, ", "))
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable 判断错误是否属于 retry_on 中的某个类别
+func (c *RetryConfig) retryable(err error) bool {
+	if len(c.RetryOn) == 0 {
+		return true
+	}
+	for _, kind := range c.RetryOn {
+		if errorKindMatches(kind, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func errorKindMatches(kind string, err error) bool {
+	var netErr net.Error
+	var statusErr HTTPStatusError
+	switch kind {
+	case RetryOnTimeout:
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	case RetryOnNetwork:
+		return errors.As(err, &netErr)
+	case RetryOnServerError:
+		return errors.As(err, &statusErr) && statusErr.HTTPStatus() >= 500 && statusErr.HTTPStatus() <= 599
+	case RetryOnRateLimited:
+		return errors.As(err, &statusErr) && statusErr.HTTPStatus() == 429
+	}
+	return false
+}
+
+// RetryNode 是一个包装节点，在被包装节点失败时按配置重试
+// 被包装的节点应当只在成功时写入 Context，避免失败的尝试留下部分结果；
+// 组合节点无法保证这一点，加载配置时不允许为其配置 retry
+type RetryNode struct {
+	Node
+	cfg RetryConfig
+}
+
+// NewRetryNode 创建一个新的重试包装节点
+func NewRetryNode(node Node, cfg RetryConfig) (*RetryNode, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &RetryNode{
+		Node: node,
+		cfg:  cfg,
+	}, nil
+}
+
+// Unwrap 返回被包装的节点
+func (n *RetryNode) Unwrap() Node {
+	return n.Node
+}
+
+// Execute 执行被包装的节点，失败时按指数退避重试
+// 上游 ctx 被取消或错误不在 retry_on 中时立即返回
+func (n *RetryNode) Execute(ctx *Context) error {
+	backoff := time.Duration(n.cfg.BackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(n.cfg.MaxBackoffMs) * time.Millisecond
+
+	var err error
+	for attempt := 1; attempt <= n.cfg.MaxAttempts; attempt++ {
+		if err = n.Node.Execute(ctx); err == nil {
+			return nil
+		}
+		if ctx.Ctx.Err() != nil || !n.cfg.retryable(err) || attempt == n.cfg.MaxAttempts {
+			break
+		}
+
+		ctx.AddLog(fmt.Sprintf("Node %s attempt %d/%d failed: %v, retrying in %v", n.Name(), attempt, n.cfg.MaxAttempts, err, backoff))
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+
+		backoff = time.Duration(float64(backoff) * n.cfg.Multiplier)
+		if maxBackoff > 0 && backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"fmt"
+)
+
+// CaseConfig 是 switch 节点的一个分支
+// When 中的所有条件同时成立时命中该分支
+type CaseConfig struct {
+	Name  string       `json:"name" yaml:"name"`
+	When  []Condition  `json:"when" yaml:"when"`
+	Nodes []NodeConfig `json:"nodes" yaml:"nodes"`
+}
+
+// switchCase 是加载后的分支
+type switchCase struct {
+	name  string
+	when  []Condition
+	nodes []Node
+}
+
+// SwitchNode 是一个组合节点，根据 Context 的状态在运行时选择分支
+// 按声明顺序匹配 cases，执行第一个命中的分支；都不命中时执行 default 分支 (可为空)
+// 分支内的节点按顺序执行
+type SwitchNode struct {
+	nodeName    string
+	cases       []switchCase
+	defaultCase []Node
+}
+
+// NewSwitchNode 创建一个新的分支节点
+func NewSwitchNode(name string, cases []switchCase, defaultCase []Node) *SwitchNode {
+	return &SwitchNode{
+		nodeName:    name,
+		cases:       cases,
+		defaultCase: defaultCase,
+	}
+}
+
+func (n *SwitchNode) Name() string {
+	return n.nodeName
+}
+
+func (n *SwitchNode) Type() string {
+	return "switch"
+}
+
+// Children 返回所有分支 (包括 default) 中的节点
+func (n *SwitchNode) Children() []Node {
+	var children []Node
+	for _, c := range n.cases {
+		children = append(children, c.nodes...)
+	}
+	return append(children, n.defaultCase...)
+}
+
+// Execute 选择第一个命中的分支并依次执行其中的节点
+func (n *SwitchNode) Execute(ctx *Context) error {
+	branchName, nodes := "default", n.defaultCase
+	for _, c := range n.cases {
+		if matchAll(ctx, c.when) {
+			branchName, nodes = c.name, c.nodes
+			break
+		}
+	}
+
+	ctx.span.SetAttribute("branch", branchName)
+	ctx.AddLog(fmt.Sprintf("SwitchNode %s selected branch: %s", n.nodeName, branchName))
+	for _, node := range nodes {
+		if err := runNode(ctx, node); err != nil {
+			return fmt.Errorf("switch node %s, branch %s: %w", n.nodeName, branchName, err)
+		}
+	}
+	return nil
+}
+
+func matchAll(ctx *Context, conditions []Condition) bool {
+	for _, cond := range conditions {
+		if !cond.Match(ctx) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutNode 是一个包装节点，为被包装的节点设置独立的超时时间
+// 超时只会取消该节点自身，不影响同一 Pipeline 中的其他节点
+type TimeoutNode struct {
+	Node
+	timeout time.Duration
+}
+
+// NewTimeoutNode 创建一个新的超时包装节点
+func NewTimeoutNode(node Node, timeout time.Duration) *TimeoutNode {
+	return &TimeoutNode{
+		Node:    node,
+		timeout: timeout,
+	}
+}
+
+// Unwrap 返回被包装的节点
+func (n *TimeoutNode) Unwrap() Node {
+	return n.Node
+}
+
+// Execute 在带有独立 deadline 的 Context 中执行被包装的节点
+func (n *TimeoutNode) Execute(ctx *Context) error {
+	nodeCtx, cancel := context.WithTimeout(ctx.Ctx, n.timeout)
+	defer cancel()
+
+	err := n.Node.Execute(ctx.WithContext(nodeCtx))
+	// 只有节点自身的 deadline 到期 (而不是上游被取消) 时才报告为节点超时
+	if err != nil && errors.Is(nodeCtx.Err(), context.DeadlineExceeded) && ctx.Ctx.Err() == nil {
+		return &nodeTimeoutError{node: n.Name(), timeout: n.timeout, err: err}
+	}
+	return err
+}
+
+// nodeTimeoutError 表示节点超过了自身的 timeout_ms
+// 无论节点返回的错误是否包装了 ctx.Err()，errors.Is(err, context.DeadlineExceeded) 都成立
+type nodeTimeoutError struct {
+	node    string
+	timeout time.Duration
+	err     error
+}
+
+func (e *nodeTimeoutError) Error() string {
+	return fmt.Sprintf("node %s timed out after %v: %v", e.node, e.timeout, e.err)
+}
+
+func (e *nodeTimeoutError) Unwrap() error { return e.err }
+
+func (e *nodeTimeoutError) Is(target error) bool { return target == context.DeadlineExceeded }
@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"recommend_engine/internal/logger"
+)
+
+// Reload 重新读取 Pipeline 配置并通过 Registry 重建所有节点
+// 新配置构建成功后才会原子替换；若新配置有误，旧配置继续生效并返回错误。
+// 已经在执行中的请求会继续使用旧的流程直至结束，之后旧流程中的节点会被关闭 (Closer)。
+func (e *Engine) Reload() error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+	if e.isClosed() {
+		return ErrEngineClosed
+	}
+
+	// 先记录修改时间再读取，避免读取期间的写入被漏掉
+	var modTime time.Time
+	if info, err := os.Stat(e.configPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	set, err := loadPipelines(e.configPath, e.registry)
+	if err != nil {
+		return err
+	}
+
+	// 新配置的节点预热完成后才会替换旧配置，预热期间的进度可以通过 Readiness 查看
+	e.loading.Store(set)
+	err = set.initNodes()
+	e.loading.Store((*pipelineSet)(nil))
+	if err != nil {
+		return err
+	}
+
+	for _, w := range set.warnings {
+		logger.Info("Pipeline config warning: %v", w)
+	}
+	old, _ := e.current.Load().(*pipelineSet)
+	e.current.Store(set)
+	e.modTime = modTime
+
+	// 旧配置在使用它的请求全部结束后关闭
+	if old != nil {
+		old.retire()
+	}
+	return nil
+}
+
+// Watch 周期性检查配置文件的修改时间，发生变化时自动 Reload
+// 阻塞直到 ctx 被取消，通常在独立的 goroutine 中调用
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := e.configChanged()
+			if err != nil {
+				logger.Error("Failed to stat pipeline config %s: %v", e.configPath, err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				logger.Error("Failed to reload pipeline config, keeping previous pipelines: %v", err)
+				// 记录本次修改时间，避免对同一份错误配置反复报错
+				e.markSeen()
+				continue
+			}
+			logger.Info("Pipeline config reloaded from %s", e.configPath)
+		}
+	}
+}
+
+// configChanged 判断配置文件自上次加载以来是否被修改
+func (e *Engine) configChanged() (bool, error) {
+	info, err := os.Stat(e.configPath)
+	if err != nil {
+		return false, fmt.Errorf("stat failed: %w", err)
+	}
+
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+	return !info.ModTime().Equal(e.modTime), nil
+}
+
+// markSeen 将当前文件修改时间记为已处理
+func (e *Engine) markSeen() {
+	info, err := os.Stat(e.configPath)
+	if err != nil {
+		return
+	}
+
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+	e.modTime = info.ModTime()
+}
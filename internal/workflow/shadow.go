@@ -0,0 +1,201 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"recommend_engine/internal/logger"
+	"recommend_engine/internal/model"
+)
+
+// DefaultShadowMaxConcurrent 未配置 max_concurrent 时每个场景同时执行的影子流程上限
+const DefaultShadowMaxConcurrent = 4
+
+// ShadowConfig 影子流程配置
+// 按比例抽样线上请求，在主流程结束后于后台执行另一个场景，结果只用于对比，不会返回给调用方
+type ShadowConfig struct {
+	Pipeline      string  `json:"pipeline" yaml:"pipeline"`                                 // 影子执行的场景名
+	SampleRate    float64 `json:"sample_rate" yaml:"sample_rate"`                           // 抽样比例，取值 [0, 1]
+	MaxConcurrent int     `json:"max_concurrent,omitempty" yaml:"max_concurrent,omitempty"` // 同时执行的影子流程上限，已满时跳过本次抽样
+}
+
+// shadow 是加载后的影子流程配置
+type shadow struct {
+	scene      string
+	sampleRate float64
+	sem        chan struct{} // 并发上限，与线上请求互不占用
+}
+
+// buildShadow 校验影子流程配置
+func buildShadow(scene string, cfg *ShadowConfig, pipelines map[string]PipelineConfig) (*shadow, error) {
+	var errs ConfigErrors
+	switch {
+	case cfg.Pipeline == "":
+		errs = append(errs, withPath("pipeline", fmt.Errorf("required field is missing")))
+	case cfg.Pipeline == scene:
+		errs = append(errs, withPath("pipeline", fmt.Errorf("shadow pipeline must differ from the primary scene")))
+	default:
+		if _, ok := pipelines[cfg.Pipeline]; !ok {
+			errs = append(errs, withPath("pipeline", fmt.Errorf("unknown pipeline '%s'", cfg.Pipeline)))
+		}
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		errs = append(errs, withPath("sample_rate", fmt.Errorf("must be between 0 and 1, got %v", cfg.SampleRate)))
+	}
+	if cfg.MaxConcurrent < 0 {
+		errs = append(errs, withPath("max_concurrent", fmt.Errorf("must not be negative, got %d", cfg.MaxConcurrent)))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = DefaultShadowMaxConcurrent
+	}
+	return &shadow{
+		scene:      cfg.Pipeline,
+		sampleRate: cfg.SampleRate,
+		sem:        make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+// sample 判断本次请求是否执行影子流程
+func (s *shadow) sample() bool {
+	return s.sampleRate > 0 && rand.Float64() < s.sampleRate
+}
+
+// ShadowStats 是某个场景影子流程的累计统计
+type ShadowStats struct {
+	Scene               string  `json:"scene"`
+	ShadowScene         string  `json:"shadow_scene"`
+	Runs                int64   `json:"runs"`                   // 已完成的影子执行次数
+	Skipped             int64   `json:"skipped"`                // 因并发已满而跳过的抽样次数
+	PrimaryErrors       int64   `json:"primary_errors"`         // 影子执行对应的主流程失败次数
+	ShadowErrors        int64   `json:"shadow_errors"`          // 影子流程失败次数
+	AvgOverlap          float64 `json:"avg_overlap"`            // 主流程与影子流程结果的平均重合度 (Jaccard)
+	AvgPrimaryLatencyMs float64 `json:"avg_primary_latency_ms"` // 主流程平均耗时
+	AvgShadowLatencyMs  float64 `json:"avg_shadow_latency_ms"`  // 影子流程平均耗时
+}
+
+// shadowCounter 累计影子执行的结果
+type shadowCounter struct {
+	stats        ShadowStats
+	overlapSum   float64
+	primaryMsSum float64
+	shadowMsSum  float64
+}
+
+// runShadow 在后台执行影子流程并与主流程的结果对比
+// shadowCtx 是主流程开始前克隆的 Context；并发已满时直接跳过，不会阻塞调用方
+func (e *Engine) runShadow(set *pipelineSet, scene string, sh *shadow, shadowCtx *Context, primary []*model.Item, primaryLatency time.Duration, primaryErr error) {
+	select {
+	case sh.sem <- struct{}{}:
+	default:
+		e.updateShadowStats(scene, sh.scene, func(c *shadowCounter) { c.stats.Skipped++ })
+		logger.Debug("Shadow %s -> %s skipped: concurrency limit reached", scene, sh.scene)
+		return
+	}
+
+	// 影子流程结束前旧配置不会被关闭；引擎正在关闭时不再启动新的影子流程
+	if !set.acquire() {
+		<-sh.sem
+		logger.Debug("Shadow %s -> %s skipped: engine is closing", scene, sh.scene)
+		return
+	}
+	shadowCtx.pipelines = set
+	shadowCtx.interceptors = e.interceptors
+	go func() {
+		defer func() { <-sh.sem }()
+		defer set.release()
+
+		start := time.Now()
+		shadowErr := set.run(shadowCtx, sh.scene)
+		shadowLatency := time.Since(start)
+
+		result := shadowCtx.GetCandidates()
+		overlap := itemOverlap(primary, result)
+		logger.Info("Shadow %s -> %s: user=%s overlap=%.2f primary=%d items/%v/err=%v shadow=%d items/%v/err=%v",
+			scene, sh.scene, shadowCtx.UserID, overlap,
+			len(primary), primaryLatency.Round(time.Millisecond), primaryErr,
+			len(result), shadowLatency.Round(time.Millisecond), shadowErr)
+
+		e.updateShadowStats(scene, sh.scene, func(c *shadowCounter) {
+			c.stats.Runs++
+			if primaryErr != nil {
+				c.stats.PrimaryErrors++
+			}
+			if shadowErr != nil {
+				c.stats.ShadowErrors++
+			}
+			c.overlapSum += overlap
+			c.primaryMsSum += float64(primaryLatency.Microseconds()) / 1000
+			c.shadowMsSum += float64(shadowLatency.Microseconds()) / 1000
+		})
+	}()
+}
+
+// updateShadowStats 更新场景的影子统计，影子场景变化 (如热更新后) 时重新计数
+func (e *Engine) updateShadowStats(scene, shadowScene string, update func(c *shadowCounter)) {
+	e.shadowMu.Lock()
+	defer e.shadowMu.Unlock()
+	c, ok := e.shadowStats[scene]
+	if !ok || c.stats.ShadowScene != shadowScene {
+		c = &shadowCounter{stats: ShadowStats{Scene: scene, ShadowScene: shadowScene}}
+		e.shadowStats[scene] = c
+	}
+	update(c)
+}
+
+// ShadowStats 返回各场景影子流程的累计统计，按场景名索引
+func (e *Engine) ShadowStats() map[string]ShadowStats {
+	e.shadowMu.Lock()
+	defer e.shadowMu.Unlock()
+	result := make(map[string]ShadowStats, len(e.shadowStats))
+	for scene, c := range e.shadowStats {
+		stats := c.stats
+		if stats.Runs > 0 {
+			n := float64(stats.Runs)
+			stats.AvgOverlap = c.overlapSum / n
+			stats.AvgPrimaryLatencyMs = c.primaryMsSum / n
+			stats.AvgShadowLatencyMs = c.shadowMsSum / n
+		}
+		result[scene] = stats
+	}
+	return result
+}
+
+// itemOverlap 按条目名称计算两个结果集的 Jaccard 相似度，都为空时返回 1
+func itemOverlap(a, b []*model.Item) float64 {
+	union := make(map[string]bool, len(a)+len(b))
+	inA := make(map[string]bool, len(a))
+	for _, item := range a {
+		inA[item.Name] = true
+		union[item.Name] = true
+	}
+	common := 0
+	inB := make(map[string]bool, len(b))
+	for _, item := range b {
+		if inA[item.Name] && !inB[item.Name] {
+			common++
+		}
+		inB[item.Name] = true
+		union[item.Name] = true
+	}
+	if len(union) == 0 {
+		return 1
+	}
+	return float64(common) / float64(len(union))
+}
+
+// shadowContext 克隆一个用于影子执行的 Context
+// 克隆拥有独立的候选集、召回结果和轨迹，使用不随请求结束而取消的 context.Background()，
+// 超时由影子场景的 timeout_ms 决定
+func (c *Context) shadowContext() *Context {
+	cp := c.Clone(context.Background())
+	cp.Debug = false
+	cp.shadow = true
+	return cp
+}
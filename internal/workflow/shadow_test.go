@@ -0,0 +1,113 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"recommend_engine/internal/model"
+)
+
+// waitShadow 等待场景的影子执行完成 n 次
+func waitShadow(t *testing.T, engine *Engine, scene string, n int64) ShadowStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := engine.ShadowStats()[scene]; stats.Runs >= n {
+			return stats
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("shadow runs for %s did not reach %d: %+v", scene, n, engine.ShadowStats()[scene])
+	return ShadowStats{}
+}
+
+func TestShadowPipeline(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"shadow": {"pipeline": "music_v2", "sample_rate": 1},
+				"nodes": [{"name": "recall", "type": "stub", "config": {"items": ["a", "b"]}}]
+			},
+			"music_v2": {
+				"nodes": [{"name": "recall", "type": "stub", "config": {"items": ["b", "c"]}}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	if err := engine.Run(ctx, "music"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// 影子流程的结果不会写入主流程的 Context
+	if got := candidateNames(ctx); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("expected primary candidates [a b], got %v", got)
+	}
+
+	stats := waitShadow(t, engine, "music", 1)
+	if stats.ShadowScene != "music_v2" || stats.ShadowErrors != 0 || stats.PrimaryErrors != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	// {a, b} 与 {b, c} 的 Jaccard 相似度为 1/3
+	if stats.AvgOverlap < 0.33 || stats.AvgOverlap > 0.34 {
+		t.Errorf("expected overlap 1/3, got %v", stats.AvgOverlap)
+	}
+}
+
+func TestShadowConcurrencyLimit(t *testing.T) {
+	path := writeConfig(t, `{
+		"pipelines": {
+			"music": {
+				"shadow": {"pipeline": "slow", "sample_rate": 1, "max_concurrent": 1},
+				"nodes": [{"name": "recall", "type": "stub", "config": {"items": ["a"]}}]
+			},
+			"slow": {
+				"timeout_ms": 100,
+				"nodes": [{"name": "hang", "type": "stub", "config": {"block": true}}]
+			}
+		}
+	}`)
+	engine, err := NewEngine(path, newTestRegistry())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+		if err := engine.Run(ctx, "music"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+	stats := waitShadow(t, engine, "music", 1)
+	if stats.Runs != 1 || stats.Skipped != 2 || stats.ShadowErrors != 1 {
+		t.Errorf("expected 1 run (timed out) and 2 skipped, got %+v", stats)
+	}
+}
+
+func TestShadowConfigErrors(t *testing.T) {
+	cases := map[string]struct {
+		shadow string
+		want   string
+	}{
+		"missing":  {`{"sample_rate": 0.1}`, "pipelines.music.shadow.pipeline: required field is missing"},
+		"unknown":  {`{"pipeline": "nope", "sample_rate": 0.1}`, "pipelines.music.shadow.pipeline: unknown pipeline 'nope'"},
+		"self":     {`{"pipeline": "music", "sample_rate": 0.1}`, "shadow pipeline must differ"},
+		"rate":     {`{"pipeline": "other", "sample_rate": 1.5}`, "pipelines.music.shadow.sample_rate: must be between 0 and 1"},
+		"negative": {`{"pipeline": "other", "sample_rate": 0.1, "max_concurrent": -1}`, "pipelines.music.shadow.max_concurrent"},
+	}
+	for name, tc := range cases {
+		content := `{"pipelines": {
+			"music": {"shadow": ` + tc.shadow + `, "nodes": [{"name": "recall", "type": "stub"}]},
+			"other": {"nodes": [{"name": "recall", "type": "stub"}]}
+		}}`
+		_, err := NewEngine(writeConfig(t, content), newTestRegistry())
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
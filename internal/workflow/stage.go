@@ -0,0 +1,245 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Resource 是节点读写的数据
+type Resource string
+
+const (
+	ResourceCandidates    Resource = "candidates"     // 候选集
+	ResourceRecallResults Resource = "recall_results" // 各路召回的原始结果
+	ResourceFavorites     Resource = "favorites"      // 用户收藏，随请求传入，总是可用
+)
+
+// Access 描述节点读写的数据以及是否会截断候选集
+type Access struct {
+	Reads     []Resource
+	Writes    []Resource
+	Truncates bool // 可能丢弃排在后面的候选 (如带 limit 的排序)
+}
+
+// AccessDeclarer 由需要声明读写数据的节点实现
+// 未实现时按 Type() 推断：recall 写入候选集和召回结果，filter / rank 读写候选集，其余类型不做假设
+type AccessDeclarer interface {
+	Access() Access
+}
+
+// accessOf 返回节点声明或按类型推断的读写数据
+func accessOf(node Node) Access {
+	if d, ok := node.(AccessDeclarer); ok {
+		return d.Access()
+	}
+	switch node.Type() {
+	case "recall":
+		return Access{Writes: []Resource{ResourceCandidates, ResourceRecallResults}}
+	case "filter", "rank":
+		return Access{Reads: []Resource{ResourceCandidates}, Writes: []Resource{ResourceCandidates}}
+	default:
+		return Access{}
+	}
+}
+
+// stageState 是执行到某个位置时数据的状态
+type stageState struct {
+	written     map[Resource]bool // 上游已经 (可能) 写入的数据
+	truncatedBy string            // 截断过候选集的节点名
+}
+
+func newStageState() stageState {
+	return stageState{written: map[Resource]bool{ResourceFavorites: true}}
+}
+
+func (s stageState) with(writes []Resource) stageState {
+	written := make(map[Resource]bool, len(s.written)+len(writes))
+	for r := range s.written {
+		written[r] = true
+	}
+	for _, r := range writes {
+		written[r] = true
+	}
+	return stageState{written: written, truncatedBy: s.truncatedBy}
+}
+
+// merge 合并两条分支的状态：任一分支写入的数据都视为可用，避免对可能执行的分支误报
+func (s stageState) merge(o stageState) stageState {
+	var writes []Resource
+	for r := range o.written {
+		writes = append(writes, r)
+	}
+	merged := s.with(writes)
+	if merged.truncatedBy == "" {
+		merged.truncatedBy = o.truncatedBy
+	}
+	return merged
+}
+
+// stageChecker 按执行顺序检查节点的阶段和读写依赖
+type stageChecker struct {
+	set      *pipelineSet
+	path     string
+	errs     ConfigErrors
+	warnings []error
+}
+
+// checkStages 在加载时检查所有场景的节点顺序
+//   - 读取候选集或召回结果的节点 (如 filter) 之前没有节点写入它们：错误，请求必然返回空结果
+//   - recall 节点位于截断候选集的 rank 节点之后：警告，新召回的条目不会经过截断
+//
+// 只被 pipeline 节点引用的子流程不单独检查，而是在引用它的位置按上游的状态检查。
+func checkStages(set *pipelineSet) (ConfigErrors, []error) {
+	included := make(map[string]bool)
+	for _, p := range set.config.Pipelines {
+		for _, nodes := range p.NodeLists() {
+			for _, target := range pipelineRefs(nodes) {
+				included[target] = true
+			}
+		}
+	}
+
+	scenes := make([]string, 0, len(set.pipelines))
+	for scene := range set.pipelines {
+		if !included[scene] {
+			scenes = append(scenes, scene)
+		}
+	}
+	sort.Strings(scenes)
+
+	c := &stageChecker{set: set}
+	for _, scene := range scenes {
+		p := set.pipelines[scene]
+		if p.experiment == nil {
+			c.path = "pipelines." + scene
+			c.sequence(p.nodes, newStageState())
+			continue
+		}
+		for i, v := range p.experiment.variants {
+			c.path = fmt.Sprintf("pipelines.%s.experiment.variants[%d]", scene, i)
+			c.sequence(v.nodes, newStageState())
+		}
+	}
+	return c.errs, c.warnings
+}
+
+func (c *stageChecker) errorf(format string, args ...interface{}) {
+	c.errs = append(c.errs, &ConfigError{Path: c.path, Err: fmt.Errorf(format, args...)})
+}
+
+func (c *stageChecker) warnf(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, &ConfigError{Path: c.path, Err: fmt.Errorf(format, args...)})
+}
+
+// sequence 依次检查顺序执行的节点，返回执行后的状态
+func (c *stageChecker) sequence(nodes []Node, in stageState) stageState {
+	state := in
+	for _, node := range nodes {
+		state = c.node(node, state)
+	}
+	return state
+}
+
+// branches 检查从同一输入出发的多条分支，返回合并后的状态
+func (c *stageChecker) branches(in stageState, branches ...[]Node) stageState {
+	out := in
+	for _, nodes := range branches {
+		out = out.merge(c.sequence(nodes, in))
+	}
+	return out
+}
+
+// node 检查单个节点，组合节点按其调度方式检查子节点
+func (c *stageChecker) node(node Node, in stageState) stageState {
+	switch n := node.(type) {
+	case *TimeoutNode:
+		return c.node(n.Node, in)
+	case *RetryNode:
+		return c.node(n.Node, in)
+	case *ParallelNode:
+		var branches [][]Node
+		for _, child := range n.children {
+			branches = append(branches, []Node{child})
+		}
+		return c.branches(in, branches...)
+	case *FallbackNode:
+		var branches [][]Node
+		for _, child := range n.children {
+			branches = append(branches, []Node{child})
+		}
+		return c.branches(in, branches...)
+	case *SwitchNode:
+		branches := [][]Node{n.defaultCase}
+		for _, sc := range n.cases {
+			branches = append(branches, sc.nodes)
+		}
+		return c.branches(in, branches...)
+	case *DAGNode:
+		return c.dag(n, in)
+	case *SubPipelineNode:
+		p, ok := c.set.pipelines[n.target]
+		if !ok {
+			return in
+		}
+		if p.experiment == nil {
+			return c.sequence(p.nodes, in)
+		}
+		var branches [][]Node
+		for _, v := range p.experiment.variants {
+			branches = append(branches, v.nodes)
+		}
+		return c.branches(in, branches...)
+	}
+
+	access := accessOf(node)
+	for _, r := range access.Reads {
+		if in.written[r] {
+			continue
+		}
+		if node.Type() == "filter" && r == ResourceCandidates {
+			c.errorf("filter node '%s' runs before any recall", node.Name())
+		} else {
+			c.errorf("%s node '%s' reads %s but no upstream node writes them", node.Type(), node.Name(), r)
+		}
+	}
+	if node.Type() == "recall" && in.truncatedBy != "" {
+		c.warnf("recall node '%s' runs after '%s' truncated the candidates, its items are not truncated", node.Name(), in.truncatedBy)
+	}
+
+	out := in.with(access.Writes)
+	if access.Truncates {
+		out.truncatedBy = node.Name()
+	}
+	return out
+}
+
+// dag 按依赖关系检查 DAG：没有依赖的节点以 DAG 的输入为起点，汇合点合并所有依赖的输出
+func (c *stageChecker) dag(n *DAGNode, in stageState) stageState {
+	outputs := make([]*stageState, len(n.vertices))
+	var visit func(i int) stageState
+	visit = func(i int) stageState {
+		if outputs[i] != nil {
+			return *outputs[i]
+		}
+		v := n.vertices[i]
+		start := in
+		if len(v.deps) > 0 {
+			start = visit(v.deps[0])
+			for _, d := range v.deps[1:] {
+				start = start.merge(visit(d))
+			}
+		}
+		out := c.node(v.node, start)
+		outputs[i] = &out
+		return out
+	}
+
+	result := in
+	for i, v := range n.vertices {
+		out := visit(i)
+		if v.sink {
+			result = result.merge(out)
+		}
+	}
+	return result
+}
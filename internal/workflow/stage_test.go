@@ -0,0 +1,109 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+// truncateNode 是测试用的截断排序节点
+type truncateNode struct{ dropNode }
+
+func (n *truncateNode) Type() string { return "rank" }
+
+func (n *truncateNode) Access() Access {
+	return Access{Reads: []Resource{ResourceCandidates}, Writes: []Resource{ResourceCandidates}, Truncates: true}
+}
+
+func newStageRegistry() *Registry {
+	r := newTestRegistry()
+	r.Register("truncate", func(cfg NodeConfig) (Node, error) {
+		return &truncateNode{dropNode{name: cfg.Name}}, nil
+	})
+	return r
+}
+
+func TestStageChecks(t *testing.T) {
+	cases := map[string]struct {
+		pipelines string
+		err       string
+		warning   string
+	}{
+		"filter before recall": {
+			pipelines: `"music": {"nodes": [
+				{"name": "dedup", "type": "drop"},
+				{"name": "recall", "type": "stub"}
+			]}`,
+			err: "pipelines.music: filter node 'dedup' runs before any recall",
+		},
+		"recall after truncation": {
+			pipelines: `"music": {"nodes": [
+				{"name": "recall", "type": "stub"},
+				{"name": "top", "type": "truncate"},
+				{"name": "late", "type": "stub"}
+			]}`,
+			warning: "pipelines.music: recall node 'late' runs after 'top' truncated the candidates",
+		},
+		"parallel recall then filter": {
+			pipelines: `"music": {"nodes": [
+				{"name": "group", "type": "parallel", "nodes": [{"name": "a", "type": "stub"}, {"name": "b", "type": "stub"}]},
+				{"name": "dedup", "type": "drop"}
+			]}`,
+		},
+		"included tail after recall": {
+			pipelines: `"tail": {"nodes": [{"name": "dedup", "type": "drop"}]},
+			"music": {"nodes": [
+				{"name": "recall", "type": "stub"},
+				{"name": "tail", "type": "pipeline", "config": {"pipeline": "tail"}}
+			]}`,
+		},
+		"included tail before recall": {
+			pipelines: `"tail": {"nodes": [{"name": "dedup", "type": "drop"}]},
+			"music": {"nodes": [
+				{"name": "tail", "type": "pipeline", "config": {"pipeline": "tail"}},
+				{"name": "recall", "type": "stub"}
+			]}`,
+			err: "pipelines.music: filter node 'dedup' runs before any recall",
+		},
+		"dag dependencies": {
+			pipelines: `"music": {"nodes": [
+				{"name": "recall", "type": "stub"},
+				{"name": "dedup", "type": "drop", "depends_on": ["recall"]},
+				{"name": "orphan", "type": "drop", "depends_on": []}
+			]}`,
+			err: "filter node 'orphan' runs before any recall",
+		},
+		"experiment variant": {
+			pipelines: `"music": {"experiment": {"variants": [
+				{"name": "a", "weight": 1, "nodes": [{"name": "recall", "type": "stub"}, {"name": "dedup", "type": "drop"}]},
+				{"name": "b", "weight": 1, "nodes": [{"name": "dedup", "type": "drop"}]}
+			]}}`,
+			err: "pipelines.music.experiment.variants[1]: filter node 'dedup' runs before any recall",
+		},
+	}
+
+	for name, tc := range cases {
+		engine, err := NewEngine(writeConfig(t, `{"pipelines": {`+tc.pipelines+`}}`), newStageRegistry())
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected error containing %q, got %v", name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		var warnings []string
+		for _, w := range engine.Warnings() {
+			warnings = append(warnings, w.Error())
+		}
+		got := strings.Join(warnings, "\n")
+		if tc.warning == "" && got != "" {
+			t.Errorf("%s: unexpected warnings: %s", name, got)
+		}
+		if tc.warning != "" && !strings.Contains(got, tc.warning) {
+			t.Errorf("%s: expected warning containing %q, got %q", name, tc.warning, got)
+		}
+	}
+}
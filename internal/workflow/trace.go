@@ -0,0 +1,163 @@
+package workflow
+
+import (
+	"sync"
+	"time"
+)
+
+// Span 状态
+const (
+	SpanRunning = "running"
+	SpanOK      = "ok"
+	SpanError   = "error"
+)
+
+// Span 是结构化执行轨迹中的一个节点
+// 每个节点的执行对应一个 Span，组合节点的子节点对应子 Span，整体构成一棵树
+type Span struct {
+	Name             string            `json:"name"`
+	Type             string            `json:"type"`
+	Start            time.Time         `json:"start"`
+	End              time.Time         `json:"end"`
+	DurationMs       float64           `json:"duration_ms"`
+	Status           string            `json:"status"`
+	Error            string            `json:"error,omitempty"`
+	CandidatesBefore int               `json:"candidates_before"`
+	CandidatesAfter  int               `json:"candidates_after"`
+	RecallSources    map[string]int    `json:"recall_sources,omitempty"` // 该节点写入的召回源及其结果数
+	Attributes       map[string]string `json:"attributes,omitempty"`
+	Logs             []string          `json:"logs,omitempty"`
+	Children         []*Span           `json:"children,omitempty"`
+
+	mu sync.Mutex // 保护子 Span、日志等在并发子节点中的写入
+}
+
+// newSpan 创建一个开始计时的 Span
+func newSpan(name, nodeType string, candidates int) *Span {
+	return &Span{
+		Name:             name,
+		Type:             nodeType,
+		Start:            time.Now(),
+		Status:           SpanRunning,
+		CandidatesBefore: candidates,
+	}
+}
+
+// startChild 创建并挂载一个子 Span
+func (s *Span) startChild(name, nodeType string, candidates int) *Span {
+	child := newSpan(name, nodeType, candidates)
+	s.mu.Lock()
+	s.Children = append(s.Children, child)
+	s.mu.Unlock()
+	return child
+}
+
+// adopt 将 from 的子 Span 挂载到 s 下，s 为 nil 时为空操作
+func (s *Span) adopt(from *Span) {
+	if s == nil {
+		return
+	}
+	from.mu.Lock()
+	children := from.Children
+	from.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Children = append(s.Children, children...)
+}
+
+// finish 结束计时并记录结果
+func (s *Span) finish(candidates int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.End = time.Now()
+	s.DurationMs = float64(s.End.Sub(s.Start).Microseconds()) / 1000
+	s.CandidatesAfter = candidates
+	if err != nil {
+		s.Status = SpanError
+		s.Error = err.Error()
+	} else {
+		s.Status = SpanOK
+	}
+}
+
+// SetAttribute 为 Span 添加一个键值属性 (如选中的分支、子流程名称)
+// 节点未在 Engine 中执行 (没有 Span) 时为空操作
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+func (s *Span) addRecall(source string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.RecallSources == nil {
+		s.RecallSources = make(map[string]int)
+	}
+	s.RecallSources[source] = count
+}
+
+func (s *Span) addLog(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Logs = append(s.Logs, msg)
+}
+
+// Find 按名称深度优先查找 Span，找不到时返回 nil
+func (s *Span) Find(name string) *Span {
+	if s.Name == name {
+		return s
+	}
+	for _, child := range s.Children {
+		if found := child.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// runNode 通过拦截器链执行单个节点并记录对应的 Span，debug 模式下同时记录候选集快照
+// Engine 以及所有组合节点都应通过它执行子节点，以保证轨迹完整、拦截器生效
+// ctx.Ctx 在执行前或执行中结束时返回 *CanceledError，调用方应当停止执行剩余的节点
+func runNode(ctx *Context, node Node) error {
+	var span *Span
+	if ctx.span != nil {
+		span = ctx.span.startChild(node.Name(), node.Type(), ctx.CandidateCount())
+	} else {
+		span = newSpan(node.Name(), node.Type(), ctx.CandidateCount())
+	}
+
+	if err := checkCanceled(ctx, node); err != nil {
+		span.finish(ctx.CandidateCount(), err)
+		return err
+	}
+
+	nodeCtx := ctx.withSpan(span)
+	if !ctx.Debug {
+		err := canceledResult(ctx, node, execute(nodeCtx, node))
+		span.finish(nodeCtx.CandidateCount(), err)
+		return err
+	}
+
+	// debug 模式：记录执行前后的候选集，生成快照
+	log := &removalLog{reasons: make(map[string]string), parent: ctx.removals}
+	nodeCtx = nodeCtx.withRemovals(log)
+	before := nodeCtx.GetCandidates()
+	err := canceledResult(ctx, node, execute(nodeCtx, node))
+	after := nodeCtx.GetCandidates()
+	span.finish(len(after), err)
+
+	status := SpanOK
+	if err != nil {
+		status = SpanError
+	}
+	ctx.addSnapshot(buildSnapshot(node, status, before, after, log))
+	return err
+}
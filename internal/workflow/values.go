@@ -0,0 +1,83 @@
+package workflow
+
+// Key 是 Context 数据区中一个带类型的键
+// 键由命名空间和名称组成，命名空间通常是写入数据的节点类型或模块名，避免不同节点之间的键冲突。
+// 类型参数保证读写两端使用相同的值类型：
+//
+//	var ArtistCounts = workflow.NewKey[map[string]int]("profile", "artist_counts")
+//
+//	workflow.Set(ctx, ArtistCounts, counts)          // 上游节点计算一次
+//	counts, ok := workflow.Get(ctx, ArtistCounts)    // 下游节点直接复用
+type Key[T any] struct {
+	namespace string
+	name      string
+}
+
+// NewKey 创建一个键，通常声明为包级变量供上下游节点共用
+func NewKey[T any](namespace, name string) Key[T] {
+	return Key[T]{namespace: namespace, name: name}
+}
+
+// String 返回 namespace/name 形式的键名
+func (k Key[T]) String() string {
+	return k.namespace + "/" + k.name
+}
+
+// valueKey 是数据区中实际使用的键，不包含类型参数
+type valueKey struct {
+	namespace string
+	name      string
+}
+
+func (k Key[T]) id() valueKey {
+	return valueKey{namespace: k.namespace, name: k.name}
+}
+
+// Set 写入一个值 (线程安全)，已存在时覆盖
+// 数据区属于整个请求：WithContext / Fork 派生的 Context 共享同一份数据，Clone 得到的副本从空开始
+func Set[T any](ctx *Context, key Key[T], value T) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.values == nil {
+		ctx.values = make(map[valueKey]interface{})
+	}
+	ctx.values[key.id()] = value
+}
+
+// Get 读取一个值 (线程安全)，不存在时返回零值和 false
+// 值以引用类型 (map、slice、指针) 存储时，读取方应当只读，修改请使用 Update
+func Get[T any](ctx *Context, key Key[T]) (T, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	value, ok := ctx.values[key.id()].(T)
+	return value, ok
+}
+
+// GetOr 读取一个值，不存在时返回 def
+func GetOr[T any](ctx *Context, key Key[T], def T) T {
+	if value, ok := Get(ctx, key); ok {
+		return value
+	}
+	return def
+}
+
+// Update 原子地读取并更新一个值，fn 收到当前值 (不存在时为零值和 false) 并返回新值
+// 适用于并行节点累加同一个值 (如计数) 的场景；fn 执行期间持有锁，不应执行耗时操作或访问 Context
+func Update[T any](ctx *Context, key Key[T], fn func(old T, ok bool) T) T {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.values == nil {
+		ctx.values = make(map[valueKey]interface{})
+	}
+	old, ok := ctx.values[key.id()].(T)
+	value := fn(old, ok)
+	ctx.values[key.id()] = value
+	return value
+}
+
+// Delete 删除一个值 (线程安全)
+func Delete[T any](ctx *Context, key Key[T]) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	delete(ctx.values, key.id())
+}
@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"recommend_engine/internal/model"
+)
+
+func TestTypedValues(t *testing.T) {
+	ctx := NewContext(context.Background(), "u1", &model.User{ID: "u1"})
+	embedding := NewKey[[]float64]("user", "embedding")
+	counts := NewKey[int]("profile", "artist_count")
+
+	if _, ok := Get(ctx, embedding); ok {
+		t.Fatal("expected missing value")
+	}
+	if got := GetOr(ctx, counts, 7); got != 7 {
+		t.Errorf("expected default 7, got %d", got)
+	}
+
+	Set(ctx, embedding, []float64{0.1, 0.2})
+	if got, ok := Get(ctx, embedding); !ok || len(got) != 2 {
+		t.Errorf("expected stored embedding, got %v (%v)", got, ok)
+	}
+
+	// 同名但类型不同的键读不到其他类型的值
+	wrongType := NewKey[string]("user", "embedding")
+	if _, ok := Get(ctx, wrongType); ok {
+		t.Error("expected type mismatch to report missing")
+	}
+
+	// Fork 得到的分支共享数据区，Clone 得到的副本从空开始
+	Set(ctx.Fork(nil), counts, 1)
+	if got, _ := Get(ctx, counts); got != 1 {
+		t.Errorf("expected value written by fork, got %d", got)
+	}
+	if _, ok := Get(ctx.Clone(context.Background()), counts); ok {
+		t.Error("expected clone to start with empty values")
+	}
+
+	Delete(ctx, counts)
+	if _, ok := Get(ctx, counts); ok {
+		t.Error("expected value to be deleted")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Update(ctx, counts, func(old int, ok bool) int { return old + 1 })
+		}()
+	}
+	wg.Wait()
+	if got, _ := Get(ctx, counts); got != 50 {
+		t.Errorf("expected 50 after concurrent updates, got %d", got)
+	}
+}
@@ -29,6 +29,21 @@ type OpenAIClient struct {
 
 type Option func(*OpenAIClient)
 
+// APIError 表示 LLM 服务返回了非 200 的状态码
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm api error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// HTTPStatus 返回状态码，供节点重试按 5xx / 429 分类
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
 func WithModel(model string) Option {
 	return func(c *OpenAIClient) {
 		c.model = model
@@ -91,7 +106,7 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, options ...
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("llm api error (status %d): %s", resp.StatusCode, string(body))
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var chatResp chatResponse